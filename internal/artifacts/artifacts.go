@@ -0,0 +1,155 @@
+package artifacts
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/go-test-runner/internal/tests"
+)
+
+// Collector copies the artifact directories of tests into a run-scoped
+// output directory once each test has finished.
+type Collector struct {
+	dir     string
+	logger  log.Logger
+	pending map[testKey][]copyJob
+}
+
+type testKey struct {
+	pkg  string
+	test string
+}
+
+type copyJob struct {
+	src string
+	dst string
+}
+
+// New returns a Collector which copies artifacts to dir. Errors copying
+// artifacts when the collector is stopped are logged to logger.
+func New(dir string, logger log.Logger) *Collector {
+	return &Collector{
+		dir:     dir,
+		logger:  logger,
+		pending: map[testKey][]copyJob{},
+	}
+}
+
+// Rewrite sets where the artifacts will be saved on tests.Artifacts
+// events, so that the other handlers can refer to the copy.
+func (c *Collector) Rewrite(e tests.Event) tests.Event {
+	ev, ok := e.Payload.(tests.Artifacts)
+	if !ok || ev.Dir == "" {
+		return e
+	}
+
+	ev.Saved = filepath.Join(c.dir, sanitize(e.Package), sanitize(e.Test), filepath.Base(ev.Dir))
+	key := testKey{pkg: e.Package, test: e.Test}
+	c.pending[key] = append(c.pending[key], copyJob{src: ev.Dir, dst: ev.Saved})
+	e.Payload = ev
+	return e
+}
+
+func (c *Collector) Handle(e tests.Event) error {
+	ev, ok := e.Payload.(tests.StateChange)
+	if !ok || !ev.NewState.Done() {
+		return nil
+	}
+
+	var errs []error
+	for key, jobs := range c.pending {
+		// Once a package is done, none of its tests will write more
+		// artifacts.
+		if key.pkg != e.Package || (e.Test != "" && key.test != e.Test) {
+			continue
+		}
+		errs = append(errs, c.copy(jobs))
+		delete(c.pending, key)
+	}
+	return errors.Join(errs...)
+}
+
+// Stop copies the artifacts of tests which never finished.
+func (c *Collector) Stop() {
+	for key, jobs := range c.pending {
+		if err := c.copy(jobs); err != nil {
+			c.logger.Log("msg", "Failed to copy artifacts", "error", err)
+		}
+		delete(c.pending, key)
+	}
+}
+
+func (c *Collector) copy(jobs []copyJob) error {
+	var errs []error
+	for _, job := range jobs {
+		if err := copyDir(job.src, job.dst); err != nil {
+			errs = append(errs, fmt.Errorf("failed to copy artifacts from %s: %w", job.src, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, 0o755)
+		case d.Type().IsRegular():
+			return copyFile(path, target)
+		default:
+			// Symlinks and other special files are not copied.
+			return nil
+		}
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// sanitize turns a package or test name into a relative path.
+func sanitize(name string) string {
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		part = strings.Map(func(r rune) rune {
+			if r < 0x20 || strings.ContainsRune(`<>:"\|?*`, r) {
+				return '_'
+			}
+			return r
+		}, part)
+		if part == "" || part == "." || part == ".." {
+			part = "_"
+		}
+		parts[i] = part
+	}
+	return filepath.Join(parts...)
+}
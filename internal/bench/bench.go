@@ -0,0 +1,211 @@
+package bench
+
+import (
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/grafana/go-test-runner/internal/tests"
+)
+
+// Key identifies a benchmark within a run.
+type Key struct {
+	Package string
+	Name    string
+}
+
+// Collector gathers benchmark results from a run. Running benchmarks
+// with -count adds multiple samples for each benchmark and unit.
+type Collector struct {
+	Samples map[Key]map[string][]float64
+
+	// pkg is the package of the benchmarks in plain `go test -bench`
+	// output, from the last "pkg:" line.
+	pkg string
+}
+
+func NewCollector() *Collector {
+	return &Collector{Samples: map[Key]map[string][]float64{}}
+}
+
+// Load collects the benchmark results from a saved `go test -json` or
+// `go test -bench` output file.
+func Load(r io.Reader) (*Collector, error) {
+	c := NewCollector()
+	goJSON := tests.NewGoJSON(r)
+	for {
+		es, err := goJSON.ReadLine()
+		if errors.Is(err, io.EOF) {
+			return c, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range es {
+			c.Handle(e)
+		}
+	}
+}
+
+func (c *Collector) Handle(e tests.Event) error {
+	var result tests.BenchmarkResult
+	switch ev := e.Payload.(type) {
+	case tests.BenchmarkResult:
+		result = ev
+	case tests.RawOutput:
+		// Allows plain `go test -bench` output to be used as a baseline.
+		// The package of its benchmarks is printed before them.
+		if pkg, ok := strings.CutPrefix(ev.Line, "pkg: "); ok {
+			c.pkg = strings.TrimSpace(pkg)
+			return nil
+		}
+		var ok bool
+		result, ok = tests.ParseBenchmark(ev.Line)
+		if !ok {
+			return nil
+		}
+		if e.Package == "" {
+			e.Package = c.pkg
+		}
+	default:
+		return nil
+	}
+
+	key := Key{Package: e.Package, Name: result.Name}
+	units, ok := c.Samples[key]
+	if !ok {
+		units = map[string][]float64{}
+		c.Samples[key] = units
+	}
+	for unit, value := range result.Metrics {
+		units[unit] = append(units[unit], value)
+	}
+	return nil
+}
+
+// Options controls when a change between two runs is considered a
+// regression.
+type Options struct {
+	// Threshold is the relative change in percent a statistically
+	// significant change must exceed to be a regression.
+	Threshold float64
+	// Alpha is the significance level for the Mann-Whitney U-test.
+	Alpha float64
+}
+
+// Comparison is the difference between the baseline and the current run
+// for a single benchmark and unit.
+type Comparison struct {
+	Key
+	Unit string
+
+	Old Summary
+	New Summary
+
+	// Delta is the relative change of the mean in percent.
+	Delta float64
+	// P is the p-value of the Mann-Whitney U-test.
+	P           float64
+	Significant bool
+	Regression  bool
+}
+
+// Summary describes the samples for one benchmark and unit.
+type Summary struct {
+	N      int
+	Mean   float64
+	StdDev float64
+}
+
+func summarize(samples []float64) Summary {
+	s := Summary{N: len(samples)}
+	if s.N == 0 {
+		return s
+	}
+	for _, v := range samples {
+		s.Mean += v
+	}
+	s.Mean /= float64(s.N)
+	if s.N > 1 {
+		for _, v := range samples {
+			s.StdDev += (v - s.Mean) * (v - s.Mean)
+		}
+		s.StdDev = math.Sqrt(s.StdDev / float64(s.N-1))
+	}
+	return s
+}
+
+// Compare returns a comparison for every benchmark and unit present in
+// both the baseline and the current run, sorted by package, name and
+// unit.
+func Compare(baseline, current *Collector, opts Options) []Comparison {
+	cs := []Comparison{}
+	for key, units := range current.Samples {
+		oldUnits, ok := baseline.Samples[key]
+		if !ok {
+			continue
+		}
+		for unit, samples := range units {
+			oldSamples, ok := oldUnits[unit]
+			if !ok {
+				continue
+			}
+			cs = append(cs, compare(key, unit, oldSamples, samples, opts))
+		}
+	}
+
+	sort.Slice(cs, func(i, j int) bool {
+		if cs[i].Package != cs[j].Package {
+			return cs[i].Package < cs[j].Package
+		}
+		if cs[i].Name != cs[j].Name {
+			return cs[i].Name < cs[j].Name
+		}
+		return cs[i].Unit < cs[j].Unit
+	})
+	return cs
+}
+
+func compare(key Key, unit string, oldSamples, newSamples []float64, opts Options) Comparison {
+	c := Comparison{
+		Key:  key,
+		Unit: unit,
+		Old:  summarize(oldSamples),
+		New:  summarize(newSamples),
+		P:    mannWhitneyU(oldSamples, newSamples),
+	}
+
+	switch {
+	case c.Old.Mean != 0:
+		c.Delta = (c.New.Mean - c.Old.Mean) / c.Old.Mean * 100
+	case c.New.Mean != 0:
+		c.Delta = math.Inf(int(math.Copysign(1, c.New.Mean)))
+	}
+
+	c.Significant = c.P < opts.Alpha
+	worse := c.Delta
+	if HigherIsBetter(unit) {
+		worse = -worse
+	}
+	c.Regression = c.Significant && worse > opts.Threshold
+	return c
+}
+
+// HigherIsBetter reports whether an increase of a metric with the given
+// unit is an improvement, which is the case for throughput such as MB/s.
+func HigherIsBetter(unit string) bool {
+	return strings.HasSuffix(unit, "/s")
+}
+
+// Regressions returns the comparisons which are regressions.
+func Regressions(cs []Comparison) []Comparison {
+	regressions := []Comparison{}
+	for _, c := range cs {
+		if c.Regression {
+			regressions = append(regressions, c)
+		}
+	}
+	return regressions
+}
@@ -0,0 +1,95 @@
+package bench
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadText(t *testing.T) {
+	c, err := Load(strings.NewReader(strings.Join([]string{
+		"goos: linux",
+		"goarch: amd64",
+		"pkg: example.com/a",
+		"BenchmarkA-8 \t1000\t100 ns/op",
+		"BenchmarkA-8 \t1000\t110 ns/op",
+		"PASS",
+		"ok  \texample.com/a\t1.000s",
+		"pkg: example.com/b",
+		"BenchmarkB-8 \t1000\t200 ns/op\t5 B/op",
+		"PASS",
+	}, "\n")))
+	require.NoError(t, err)
+
+	require.Equal(t, map[Key]map[string][]float64{
+		{Package: "example.com/a", Name: "BenchmarkA"}: {"ns/op": {100, 110}},
+		{Package: "example.com/b", Name: "BenchmarkB"}: {"ns/op": {200}, "B/op": {5}},
+	}, c.Samples)
+}
+
+func TestMannWhitneyU(t *testing.T) {
+	seq := func(from, to float64) []float64 {
+		var xs []float64
+		for x := from; x <= to; x++ {
+			xs = append(xs, x)
+		}
+		return xs
+	}
+	for name, tc := range map[string]struct {
+		xs, ys []float64
+		p      float64
+	}{
+		"separated":   {xs: seq(1, 5), ys: seq(6, 10), p: 2.0 / 252},
+		"reversed":    {xs: seq(6, 10), ys: seq(1, 5), p: 2.0 / 252},
+		"interleaved": {xs: []float64{1, 3, 5, 7, 9}, ys: []float64{2, 4, 6, 8, 10}, p: 0.6904761904761905},
+		"identical":   {xs: seq(1, 5), ys: seq(1, 5), p: 1},
+		// Ties use the normal approximation with tie and continuity
+		// corrections.
+		"ties":  {xs: []float64{1, 2, 2, 3, 3}, ys: []float64{3, 4, 4, 5, 6}, p: 0.01924356579907484},
+		"large": {xs: seq(1, 30), ys: seq(31, 60), p: 3.019859359162151e-11},
+		"n=1":   {xs: []float64{1}, ys: seq(2, 10), p: 0.2},
+		"n=1+1": {xs: []float64{1}, ys: []float64{2}, p: 1},
+		"empty": {xs: nil, ys: seq(1, 5), p: 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.InDelta(t, tc.p, mannWhitneyU(tc.xs, tc.ys), 1e-12)
+		})
+	}
+}
+
+func TestExactCDF(t *testing.T) {
+	// The 6 arrangements of 2+2 samples have U = 0, 1, 2, 2, 3 and 4.
+	for u, p := range []float64{1.0 / 6, 2.0 / 6, 4.0 / 6, 5.0 / 6, 1} {
+		require.InDelta(t, p, exactCDF(2, 2, u), 1e-12, "U <= %d", u)
+	}
+	require.InDelta(t, 1.0/252, exactCDF(5, 5, 0), 1e-12)
+}
+
+func TestCompare(t *testing.T) {
+	low := []float64{100, 101, 102, 103, 104}
+	high := []float64{110, 111, 112, 113, 114}
+	opts := Options{Threshold: 5, Alpha: 0.05}
+	for name, tc := range map[string]struct {
+		unit     string
+		old, new []float64
+		opts     Options
+		delta    float64
+		regress  bool
+	}{
+		"slower":            {unit: "ns/op", old: low, new: high, opts: opts, delta: 10.0 / 102 * 100, regress: true},
+		"faster":            {unit: "ns/op", old: high, new: low, opts: opts, delta: -10.0 / 112 * 100},
+		"below threshold":   {unit: "ns/op", old: low, new: high, opts: Options{Threshold: 10, Alpha: 0.05}, delta: 10.0 / 102 * 100},
+		"not significant":   {unit: "ns/op", old: low, new: high, opts: Options{Threshold: 5, Alpha: 0.005}, delta: 10.0 / 102 * 100},
+		"higher throughput": {unit: "MB/s", old: low, new: high, opts: opts, delta: 10.0 / 102 * 100},
+		"lower throughput":  {unit: "MB/s", old: high, new: low, opts: opts, delta: -10.0 / 112 * 100, regress: true},
+		"from zero":         {unit: "B/op", old: []float64{0, 0, 0}, new: []float64{8, 8, 8}, opts: opts, delta: math.Inf(1), regress: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := compare(Key{Name: "BenchmarkA"}, tc.unit, tc.old, tc.new, tc.opts)
+			require.InDelta(t, tc.delta, c.Delta, 1e-9)
+			require.Equal(t, tc.regress, c.Regression)
+		})
+	}
+}
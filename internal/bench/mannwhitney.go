@@ -0,0 +1,116 @@
+package bench
+
+import (
+	"math"
+	"sort"
+)
+
+// exactLimit is the largest combined sample size for which the exact
+// distribution of U is computed instead of the normal approximation.
+const exactLimit = 50
+
+// mannWhitneyU returns the two-sided p-value of the Mann-Whitney U-test
+// for the hypothesis that xs and ys are drawn from the same
+// distribution. It returns 1 if either sample is empty.
+func mannWhitneyU(xs, ys []float64) float64 {
+	n1, n2 := len(xs), len(ys)
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+
+	type sample struct {
+		value float64
+		first bool
+	}
+	all := make([]sample, 0, n1+n2)
+	for _, x := range xs {
+		all = append(all, sample{value: x, first: true})
+	}
+	for _, y := range ys {
+		all = append(all, sample{value: y})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].value < all[j].value })
+
+	// Assign ranks, averaging the ranks of tied values.
+	rankSum := 0.0
+	tieCorrection := 0.0
+	ties := false
+	for i := 0; i < len(all); {
+		j := i + 1
+		for j < len(all) && all[j].value == all[i].value {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].first {
+				rankSum += rank
+			}
+		}
+		if t := float64(j - i); t > 1 {
+			ties = true
+			tieCorrection += t*t*t - t
+		}
+		i = j
+	}
+
+	u := rankSum - float64(n1*(n1+1))/2
+	// Use the smaller of U1 and U2 for the two-sided test.
+	u = math.Min(u, float64(n1*n2)-u)
+
+	if !ties && n1+n2 <= exactLimit {
+		return math.Min(1, 2*exactCDF(n1, n2, int(u)))
+	}
+
+	n := float64(n1 + n2)
+	mean := float64(n1*n2) / 2
+	variance := float64(n1*n2) / 12 * ((n + 1) - tieCorrection/(n*(n-1)))
+	if variance <= 0 {
+		return 1
+	}
+	// Continuity correction towards the mean.
+	z := (u - mean + 0.5) / math.Sqrt(variance)
+	return math.Min(1, 2*normalCDF(z))
+}
+
+// exactCDF returns P(U <= u) for samples of size n1 and n2 without ties.
+func exactCDF(n1, n2, u int) float64 {
+	// counts[i][j][k] is the number of arrangements of i and j samples
+	// with U == k.
+	maxU := n1 * n2
+	counts := make([][][]float64, n1+1)
+	for i := range counts {
+		counts[i] = make([][]float64, n2+1)
+		for j := range counts[i] {
+			counts[i][j] = make([]float64, maxU+1)
+		}
+	}
+	for i := 0; i <= n1; i++ {
+		for j := 0; j <= n2; j++ {
+			if i == 0 || j == 0 {
+				counts[i][j][0] = 1
+				continue
+			}
+			for k := 0; k <= i*j; k++ {
+				// The largest value is either from the first sample,
+				// which adds j to U, or from the second sample.
+				if k >= j {
+					counts[i][j][k] += counts[i-1][j][k-j]
+				}
+				counts[i][j][k] += counts[i][j-1][k]
+			}
+		}
+	}
+
+	total, below := 0.0, 0.0
+	for k, c := range counts[n1][n2] {
+		total += c
+		if k <= u {
+			below += c
+		}
+	}
+	return below / total
+}
+
+func normalCDF(z float64) float64 {
+	return 0.5 * math.Erfc(-z/math.Sqrt2)
+}
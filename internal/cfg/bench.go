@@ -0,0 +1,41 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const (
+	BenchRegressionThreshold = "BENCH_REGRESSION_THRESHOLD"
+	BenchAlpha               = "BENCH_ALPHA"
+)
+
+type BenchOptions struct {
+	RegressionThreshold float64
+	Alpha               float64
+}
+
+func (c Config) Bench() (BenchOptions, error) {
+	rawThreshold, rawThresholdErr := c.Get(BenchRegressionThreshold)
+	rawAlpha, rawAlphaErr := c.Get(BenchAlpha)
+
+	if err := errors.Join(rawThresholdErr, rawAlphaErr); err != nil {
+		return BenchOptions{}, fmt.Errorf("failed to get benchmark configuration options: %w", err)
+	}
+
+	threshold, thresholdErr := strconv.ParseFloat(rawThreshold, 64)
+	alpha, alphaErr := strconv.ParseFloat(rawAlpha, 64)
+	if alphaErr == nil && (alpha <= 0 || alpha >= 1) {
+		alphaErr = fmt.Errorf("benchmark alpha must be between 0 and 1, got %v", alpha)
+	}
+
+	if err := errors.Join(thresholdErr, alphaErr); err != nil {
+		return BenchOptions{}, fmt.Errorf("failed to parse benchmark configuration options: %w", err)
+	}
+
+	return BenchOptions{
+		RegressionThreshold: threshold,
+		Alpha:               alpha,
+	}, nil
+}
@@ -17,14 +17,50 @@ var defaults = Tags{
 	LokiBatchSize: "250",
 
 	TracingKind:         "jaeger",
-	TracingURL:          "http://localhost:14268/api/traces",
+	TracingURL:          "",
 	TracingLogsAsEvents: "false",
+	TracingHeaders:      "",
+	TracingInsecure:     "false",
+	TracingCAFile:       "",
+	TracingCompression:  "none",
+	TracingTimeout:      "10s",
+
+	TracingSpanNameRun:     "test/go",
+	TracingSpanNamePackage: "test/package",
+	TracingSpanNameTest:    "test/runTest",
+	TracingSpanNameSkipped: "test/skipPackage",
 
 	ConsoleLevel: "raw",
 
 	GrafanaURL:               "http://localhost:3000/",
 	GrafanaLokiDatasource:    "loki",
 	GrafanaLokiDatasourceUID: "loki",
+
+	ExitOnTelemetryError: "false",
+
+	OutputDir: "",
+
+	WrapExecShim: "true",
+
+	ProfileEnabled:      "false",
+	ProfileMinDuration:  "0s",
+	ProfilePyroscopeURL: "",
+
+	ResourceDetectCI:  "true",
+	ResourceDetectGit: "true",
+	ResourceDetectGo:  "true",
+
+	RunID:       "",
+	RunShard:    "",
+	RunIDFromCI: "false",
+	TraceID:     "",
+	// The W3C trace context is commonly passed on using the environment
+	// variables without the GT_ prefix.
+	TraceParent: os.Getenv(TraceParent),
+	TraceState:  os.Getenv(TraceState),
+
+	BenchRegressionThreshold: "5",
+	BenchAlpha:               "0.05",
 }
 
 func (c Config) Get(key string) (string, error) {
@@ -0,0 +1,31 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const (
+	ExitOnTelemetryError = "EXIT_ON_TELEMETRY_ERROR"
+)
+
+type ExitOptions struct {
+	TelemetryErrors bool
+}
+
+func (c Config) Exit() (ExitOptions, error) {
+	rawTelemetryErrors, rawTelemetryErrorsErr := c.Get(ExitOnTelemetryError)
+
+	if err := errors.Join(rawTelemetryErrorsErr); err != nil {
+		return ExitOptions{}, fmt.Errorf("failed to get exit configuration options: %w", err)
+	}
+
+	telemetryErrors, telemetryErrorsErr := strconv.ParseBool(rawTelemetryErrors)
+
+	if err := errors.Join(telemetryErrorsErr); err != nil {
+		return ExitOptions{}, fmt.Errorf("failed to parse exit configuration options: %w", err)
+	}
+
+	return ExitOptions{TelemetryErrors: telemetryErrors}, nil
+}
@@ -0,0 +1,24 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	OutputDir = "OUTPUT_DIR"
+)
+
+type OutputOptions struct {
+	Dir string
+}
+
+func (c Config) Output() (OutputOptions, error) {
+	dir, dirErr := c.Get(OutputDir)
+
+	if err := errors.Join(dirErr); err != nil {
+		return OutputOptions{}, fmt.Errorf("failed to get output configuration options: %w", err)
+	}
+
+	return OutputOptions{Dir: dir}, nil
+}
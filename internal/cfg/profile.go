@@ -0,0 +1,58 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	ProfileEnabled      = "PROFILE_ENABLED"
+	ProfileMinDuration  = "PROFILE_MIN_DURATION"
+	ProfilePyroscopeURL = "PROFILE_PYROSCOPE_URL"
+)
+
+type ProfileOptions struct {
+	// Enabled makes the exec shim save CPU and heap profiles of the test
+	// binaries.
+	Enabled bool
+	// MinDuration is how long a test binary must run for its profiles to
+	// be kept.
+	MinDuration time.Duration
+	// PyroscopeURL is where the profiles are pushed to, if set.
+	PyroscopeURL string
+}
+
+func (c Config) Profile() (ProfileOptions, error) {
+	rawEnabled, rawEnabledErr := c.Get(ProfileEnabled)
+	rawMinDuration, rawMinDurationErr := c.Get(ProfileMinDuration)
+	pyroscopeURL, pyroscopeURLErr := c.Get(ProfilePyroscopeURL)
+	outputDir, outputDirErr := c.Get(OutputDir)
+
+	if err := errors.Join(rawEnabledErr, rawMinDurationErr, pyroscopeURLErr, outputDirErr); err != nil {
+		return ProfileOptions{}, fmt.Errorf("failed to get profile configuration options: %w", err)
+	}
+
+	enabled, enabledErr := strconv.ParseBool(rawEnabled)
+	minDuration, minDurationErr := time.ParseDuration(rawMinDuration)
+	var pyroscopeURLParseErr error
+	if pyroscopeURL != "" {
+		_, pyroscopeURLParseErr = url.Parse(pyroscopeURL)
+	}
+	var outputDirMissingErr error
+	if enabled && outputDir == "" {
+		outputDirMissingErr = fmt.Errorf("%s requires %s to be set", ProfileEnabled, OutputDir)
+	}
+
+	if err := errors.Join(enabledErr, minDurationErr, pyroscopeURLParseErr, outputDirMissingErr); err != nil {
+		return ProfileOptions{}, fmt.Errorf("failed to parse profile configuration options: %w", err)
+	}
+
+	return ProfileOptions{
+		Enabled:      enabled,
+		MinDuration:  minDuration,
+		PyroscopeURL: pyroscopeURL,
+	}, nil
+}
@@ -0,0 +1,47 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const (
+	ResourceDetectCI  = "RESOURCE_DETECT_CI"
+	ResourceDetectGit = "RESOURCE_DETECT_GIT"
+	ResourceDetectGo  = "RESOURCE_DETECT_GO"
+)
+
+// ResourceOptions selects where the attributes describing the environment
+// of the run are detected from.
+type ResourceOptions struct {
+	// CI detects the repository, branch, commit, pipeline URL and actor
+	// from the environment variables of the CI system.
+	CI bool
+	// Git reads the commit, branch, dirty state, commit author and
+	// commit time from the git repository of the working tree.
+	Git bool
+	// Go detects the Go version, GOOS, GOARCH, GOFLAGS and module path
+	// using `go env`.
+	Go bool
+}
+
+func (c Config) Resource() (ResourceOptions, error) {
+	rawCI, rawCIErr := c.Get(ResourceDetectCI)
+	rawGit, rawGitErr := c.Get(ResourceDetectGit)
+	rawGo, rawGoErr := c.Get(ResourceDetectGo)
+
+	if err := errors.Join(rawCIErr, rawGitErr, rawGoErr); err != nil {
+		return ResourceOptions{}, fmt.Errorf("failed to get resource configuration options: %w", err)
+	}
+
+	detectCI, detectCIErr := strconv.ParseBool(rawCI)
+	detectGit, detectGitErr := strconv.ParseBool(rawGit)
+	detectGo, detectGoErr := strconv.ParseBool(rawGo)
+
+	if err := errors.Join(detectCIErr, detectGitErr, detectGoErr); err != nil {
+		return ResourceOptions{}, fmt.Errorf("failed to parse resource configuration options: %w", err)
+	}
+
+	return ResourceOptions{CI: detectCI, Git: detectGit, Go: detectGo}, nil
+}
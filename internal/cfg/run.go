@@ -0,0 +1,112 @@
+package cfg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/grafana/go-test-runner/internal/ci"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	RunID       = "RUN_ID"
+	RunShard    = "RUN_SHARD"
+	RunIDFromCI = "RUN_ID_FROM_CI"
+	TraceID     = "TRACE_ID"
+	TraceParent = "TRACEPARENT"
+	TraceState  = "TRACESTATE"
+)
+
+type RunOptions struct {
+	// ID identifies a run across multiple invocations of go-test-runner,
+	// such as the shards or reruns of a CI job.
+	ID string
+	// Attempt identifies the attempt of running a CI job with the ID.
+	Attempt string
+	// TraceID is used for the run when set, rather than one derived
+	// from ID and Attempt. It is the trace ID of Parent when set.
+	TraceID trace.TraceID
+	// Parent is the span the run's root span is a child of, from a W3C
+	// traceparent and tracestate.
+	Parent trace.SpanContext
+	// Shard is the name of the part of the run handled by this
+	// invocation.
+	Shard string
+}
+
+// TraceSeed returns the value the trace ID is derived from, or an empty
+// string when the trace ID should be random.
+func (o RunOptions) TraceSeed() string {
+	switch {
+	case o.ID != "" && o.Attempt != "":
+		return o.ID + "/" + o.Attempt
+	case o.ID != "":
+		return o.ID
+	case o.Parent.IsValid():
+		// Separate runs in the same trace get separate root spans.
+		return o.Parent.TraceID().String() + "/" + o.Parent.SpanID().String()
+	}
+	return ""
+}
+
+func (c Config) Run() (RunOptions, error) {
+	id, idErr := c.Get(RunID)
+	shard, shardErr := c.Get(RunShard)
+	rawFromCI, rawFromCIErr := c.Get(RunIDFromCI)
+	rawTraceID, rawTraceIDErr := c.Get(TraceID)
+	traceParent, traceParentErr := c.Get(TraceParent)
+	traceState, traceStateErr := c.Get(TraceState)
+
+	if err := errors.Join(idErr, shardErr, rawFromCIErr, rawTraceIDErr, traceParentErr, traceStateErr); err != nil {
+		return RunOptions{}, fmt.Errorf("failed to get run configuration options: %w", err)
+	}
+
+	fromCI, fromCIErr := strconv.ParseBool(rawFromCI)
+	var traceID trace.TraceID
+	var traceIDErr error
+	if rawTraceID != "" {
+		traceID, traceIDErr = trace.TraceIDFromHex(rawTraceID)
+	}
+	var parent trace.SpanContext
+	var parentErr error
+	if traceParent != "" {
+		parent = trace.SpanContextFromContext(propagation.TraceContext{}.Extract(context.Background(), propagation.MapCarrier{
+			"traceparent": traceParent,
+			"tracestate":  traceState,
+		}))
+		switch {
+		case !parent.IsValid():
+			parentErr = fmt.Errorf("%s is not a valid W3C traceparent: %q", TraceParent, traceParent)
+		case traceID.IsValid() && traceID != parent.TraceID():
+			parentErr = fmt.Errorf("%s and %s have different trace IDs", TraceID, TraceParent)
+		default:
+			traceID = parent.TraceID()
+		}
+	}
+
+	if err := errors.Join(fromCIErr, traceIDErr, parentErr); err != nil {
+		return RunOptions{}, fmt.Errorf("failed to parse run configuration options: %w", err)
+	}
+
+	attempt := ""
+	if fromCI && id == "" {
+		if info, ok := ci.Detect(); ok {
+			id, attempt = info.RunID(), info.Attempt
+		}
+	}
+
+	if shard != "" && id == "" && !traceID.IsValid() {
+		return RunOptions{}, fmt.Errorf("failed to parse run configuration options: %s requires %s or %s to be set", RunShard, RunID, TraceID)
+	}
+
+	return RunOptions{
+		ID:      id,
+		Attempt: attempt,
+		TraceID: traceID,
+		Parent:  parent,
+		Shard:   shard,
+	}, nil
+}
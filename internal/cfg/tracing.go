@@ -3,43 +3,242 @@ package cfg
 import (
 	"errors"
 	"fmt"
+	"io"
+	"net/url"
+	"sort"
 	"strconv"
+	"strings"
+	"text/template"
+	"time"
 )
 
 const (
 	TracingKind         = "TRACING_KIND"
 	TracingURL          = "TRACING_URL"
 	TracingLogsAsEvents = "TRACING_LOGS_AS_EVENTS"
+	TracingHeaders      = "TRACING_HEADERS"
+	TracingInsecure     = "TRACING_INSECURE"
+	TracingCAFile       = "TRACING_CA_FILE"
+	TracingCompression  = "TRACING_COMPRESSION"
+	TracingTimeout      = "TRACING_TIMEOUT"
+
+	TracingSpanNameRun     = "TRACING_SPAN_NAME_RUN"
+	TracingSpanNamePackage = "TRACING_SPAN_NAME_PACKAGE"
+	TracingSpanNameTest    = "TRACING_SPAN_NAME_TEST"
+	TracingSpanNameSkipped = "TRACING_SPAN_NAME_SKIPPED"
+)
+
+type TraceExporter int
+
+const (
+	TraceExporterUnknown TraceExporter = iota
+	TraceExporterJaeger
+	TraceExporterOTLPGRPC
+	TraceExporterOTLPHTTP
+	TraceExporterZipkin
+	TraceExporterFile
+	TraceExporterNone
 )
 
+func (e TraceExporter) String() string {
+	switch e {
+	case TraceExporterJaeger:
+		return "jaeger"
+	case TraceExporterOTLPGRPC:
+		return "otlp-grpc"
+	case TraceExporterOTLPHTTP:
+		return "otlp-http"
+	case TraceExporterZipkin:
+		return "zipkin"
+	case TraceExporterFile:
+		return "file"
+	case TraceExporterNone:
+		return "none"
+	default:
+		return "unknown"
+	}
+}
+
+func traceExporterFrom(s string) TraceExporter {
+	switch s {
+	case "jaeger":
+		return TraceExporterJaeger
+	case "otlp-grpc":
+		return TraceExporterOTLPGRPC
+	case "otlp-http":
+		return TraceExporterOTLPHTTP
+	case "zipkin":
+		return TraceExporterZipkin
+	case "file":
+		return TraceExporterFile
+	case "none":
+		return TraceExporterNone
+	default:
+		return TraceExporterUnknown
+	}
+}
+
+// defaultTracingURLs are the URLs used for each kind of tracing system
+// when TRACING_URL is empty.
+var defaultTracingURLs = map[TraceExporter]string{
+	TraceExporterJaeger:   "http://localhost:14268/api/traces",
+	TraceExporterOTLPGRPC: "http://localhost:4317",
+	TraceExporterOTLPHTTP: "http://localhost:4318",
+	TraceExporterZipkin:   "http://localhost:9411/api/v2/spans",
+}
+
 type TracingOptions struct {
-	Kind         string
+	Kind         TraceExporter
 	URL          string
 	LogsAsEvents bool
+	// Headers are sent with every OTLP export request.
+	Headers map[string]string
+	// Insecure disables verifying the certificate of the tracing system
+	// for https URLs.
+	Insecure bool
+	// CAFile is a PEM file with the certificate authorities used to
+	// verify the certificate of the tracing system, instead of the
+	// system's.
+	CAFile string
+	// Compression is either "none" or "gzip".
+	Compression string
+	Timeout     time.Duration
+	SpanNames   SpanNames
+}
+
+// SpanNames are templates for the names of spans. They are executed
+// with a SpanNameData. A nil template means that the default name is
+// used.
+type SpanNames struct {
+	Run     *template.Template
+	Package *template.Template
+	Test    *template.Template
+	// Skipped replaces the name of skipped packages and tests.
+	Skipped *template.Template
+}
+
+// SpanNameData is what the span name templates are executed with.
+type SpanNameData struct {
+	Package string
+	Test    string
+	Shard   string
+}
+
+// HeadersString returns Headers on the same format as TRACING_HEADERS.
+func (o TracingOptions) HeadersString() string {
+	headers := make([]string, 0, len(o.Headers))
+	for key, value := range o.Headers {
+		headers = append(headers, key+"="+url.QueryEscape(value))
+	}
+	sort.Strings(headers)
+	return strings.Join(headers, ",")
 }
 
 func (c Config) Tracing() (TracingOptions, error) {
-	kind, rawKindErr := c.Get(TracingKind)
-	url, urlErr := c.Get(TracingURL)
+	rawKind, rawKindErr := c.Get(TracingKind)
+	rawURL, urlErr := c.Get(TracingURL)
 	rawLogsAsEvents, rawLogsAsEventsErr := c.Get(TracingLogsAsEvents)
+	rawHeaders, rawHeadersErr := c.Get(TracingHeaders)
+	rawInsecure, rawInsecureErr := c.Get(TracingInsecure)
+	caFile, caFileErr := c.Get(TracingCAFile)
+	compression, compressionErr := c.Get(TracingCompression)
+	rawTimeout, rawTimeoutErr := c.Get(TracingTimeout)
+	rawSpanNameRun, rawSpanNameRunErr := c.Get(TracingSpanNameRun)
+	rawSpanNamePackage, rawSpanNamePackageErr := c.Get(TracingSpanNamePackage)
+	rawSpanNameTest, rawSpanNameTestErr := c.Get(TracingSpanNameTest)
+	rawSpanNameSkipped, rawSpanNameSkippedErr := c.Get(TracingSpanNameSkipped)
 
-	if err := errors.Join(urlErr, rawKindErr, rawLogsAsEventsErr); err != nil {
+	if err := errors.Join(urlErr, rawKindErr, rawLogsAsEventsErr, rawHeadersErr, rawInsecureErr, caFileErr, compressionErr, rawTimeoutErr,
+		rawSpanNameRunErr, rawSpanNamePackageErr, rawSpanNameTestErr, rawSpanNameSkippedErr); err != nil {
 		return TracingOptions{}, fmt.Errorf("failed to get tracing configuration options: %w", err)
 	}
 
 	var kindErr error
-	if kind != "jaeger" {
-		kindErr = fmt.Errorf("kind must be 'jaeger'")
+	kind := traceExporterFrom(rawKind)
+	if kind == TraceExporterUnknown {
+		kindErr = fmt.Errorf("unknown tracing kind '%s', expected (jaeger|otlp-grpc|otlp-http|zipkin|file|none)", rawKind)
+	}
+	var parsedURLErr error
+	switch {
+	case rawURL == "" && kind == TraceExporterFile:
+		parsedURLErr = fmt.Errorf("tracing URL is required for the file tracing kind")
+	case rawURL == "":
+		rawURL = defaultTracingURLs[kind]
+	case kind == TraceExporterOTLPGRPC && !strings.Contains(rawURL, "://"):
+		// gRPC endpoints are commonly given as host:port, which are
+		// sent with TLS like https URLs.
+		rawURL = "https://" + rawURL
+	}
+	if parsedURLErr == nil {
+		_, parsedURLErr = url.Parse(rawURL)
 	}
 	logsAsEvents, logsAsEventsErr := strconv.ParseBool(rawLogsAsEvents)
+	headers, headersErr := parseHeaders(rawHeaders)
+	insecure, insecureErr := strconv.ParseBool(rawInsecure)
+	var compressionValueErr error
+	if compression != "none" && compression != "gzip" {
+		compressionValueErr = fmt.Errorf("unknown tracing compression '%s', expected (none|gzip)", compression)
+	}
+	timeout, timeoutErr := time.ParseDuration(rawTimeout)
+	spanNameRun, spanNameRunErr := parseSpanName(TracingSpanNameRun, rawSpanNameRun)
+	spanNamePackage, spanNamePackageErr := parseSpanName(TracingSpanNamePackage, rawSpanNamePackage)
+	spanNameTest, spanNameTestErr := parseSpanName(TracingSpanNameTest, rawSpanNameTest)
+	spanNameSkipped, spanNameSkippedErr := parseSpanName(TracingSpanNameSkipped, rawSpanNameSkipped)
 
-	if err := errors.Join(kindErr, logsAsEventsErr); err != nil {
+	if err := errors.Join(kindErr, parsedURLErr, logsAsEventsErr, headersErr, insecureErr, compressionValueErr, timeoutErr,
+		spanNameRunErr, spanNamePackageErr, spanNameTestErr, spanNameSkippedErr); err != nil {
 		return TracingOptions{}, fmt.Errorf("failed to parse tracing configuration options: %w", err)
 	}
 
 	return TracingOptions{
-		Kind:         TracingKind,
-		URL:          url,
+		Kind:         kind,
+		URL:          rawURL,
 		LogsAsEvents: logsAsEvents,
+		Headers:      headers,
+		Insecure:     insecure,
+		CAFile:       caFile,
+		Compression:  compression,
+		Timeout:      timeout,
+		SpanNames: SpanNames{
+			Run:     spanNameRun,
+			Package: spanNamePackage,
+			Test:    spanNameTest,
+			Skipped: spanNameSkipped,
+		},
 	}, nil
 }
+
+// parseSpanName parses a span name template and executes it once, since
+// errors like unknown fields are only found when it's executed.
+func parseSpanName(key, s string) (*template.Template, error) {
+	tmpl, err := template.New(key).Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	data := SpanNameData{Package: "example.com/pkg", Test: "TestExample/sub", Shard: "shard"}
+	if err := tmpl.Execute(io.Discard, data); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// parseHeaders parses a comma separated list of key=value pairs, as used
+// by OTEL_EXPORTER_OTLP_HEADERS.
+func parseHeaders(s string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, header := range strings.Split(s, ",") {
+		if strings.TrimSpace(header) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(header, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected tracing headers to have the format 'key=value'")
+		}
+		value, err := url.QueryUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("failed to unescape value for tracing header %s: %w", key, err)
+		}
+		headers[strings.TrimSpace(key)] = value
+	}
+	return headers, nil
+}
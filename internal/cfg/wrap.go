@@ -0,0 +1,33 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const (
+	WrapExecShim = "WRAP_EXEC_SHIM"
+)
+
+type WrapOptions struct {
+	// ExecShim makes go-test-runner run the test binaries of a wrapped
+	// `go test` command using `go test -exec`.
+	ExecShim bool
+}
+
+func (c Config) Wrap() (WrapOptions, error) {
+	rawExecShim, rawExecShimErr := c.Get(WrapExecShim)
+
+	if err := errors.Join(rawExecShimErr); err != nil {
+		return WrapOptions{}, fmt.Errorf("failed to get wrap configuration options: %w", err)
+	}
+
+	execShim, execShimErr := strconv.ParseBool(rawExecShim)
+
+	if err := errors.Join(execShimErr); err != nil {
+		return WrapOptions{}, fmt.Errorf("failed to parse wrap configuration options: %w", err)
+	}
+
+	return WrapOptions{ExecShim: execShim}, nil
+}
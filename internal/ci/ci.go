@@ -0,0 +1,195 @@
+package ci
+
+import (
+	"os"
+	"strings"
+)
+
+// Info describes the CI job go-test-runner is running in.
+type Info struct {
+	// Provider is the name of the CI system, e.g. "github".
+	Provider string
+	// Pipeline identifies the pipeline, workflow run or build the job
+	// is a part of.
+	Pipeline string
+	// Job identifies the job within the pipeline. It stays the same
+	// when the job is retried, and is empty for CI systems where a
+	// pipeline is a single job.
+	Job string
+	// Attempt identifies the attempt of running the job. For CI systems
+	// where rerunning a job creates a new build, it is the build number.
+	Attempt string
+
+	// Repository is the URL of the repository being built.
+	Repository string
+	// Branch is the name of the branch being built. For pull requests,
+	// it is the branch the changes are made on.
+	Branch string
+	// Commit is the hash of the commit being built.
+	Commit string
+	// URL links to the pipeline in the CI system's UI.
+	URL string
+	// Actor is the user who triggered the pipeline.
+	Actor string
+}
+
+// RunID returns an identifier for the job which is the same for every
+// attempt of running it.
+func (i Info) RunID() string {
+	if i.Job == "" {
+		return i.Provider + "/" + i.Pipeline
+	}
+	return strings.Join([]string{i.Provider, i.Pipeline, i.Job}, "/")
+}
+
+type provider struct {
+	name     string
+	detect   string
+	pipeline []string
+	job      []string
+	// jobOptional is true when the pipeline can be a single job.
+	jobOptional bool
+	attempt     string
+
+	// The details are lists of candidates expanded using os.ExpandEnv,
+	// the first one without any unset variables is used.
+	repository []string
+	branch     []string
+	commit     []string
+	url        []string
+	actor      []string
+}
+
+var providers = []provider{
+	{
+		name:     "github",
+		detect:   "GITHUB_ACTIONS",
+		pipeline: []string{"GITHUB_RUN_ID"},
+		// The legs of a matrix job share GITHUB_JOB, the matrix isn't
+		// available in the environment.
+		job:     []string{"GITHUB_JOB"},
+		attempt: "GITHUB_RUN_ATTEMPT",
+
+		repository: []string{"$GITHUB_SERVER_URL/$GITHUB_REPOSITORY"},
+		branch:     []string{"$GITHUB_HEAD_REF", "$GITHUB_REF_NAME"},
+		commit:     []string{"$GITHUB_SHA"},
+		url:        []string{"$GITHUB_SERVER_URL/$GITHUB_REPOSITORY/actions/runs/$GITHUB_RUN_ID"},
+		actor:      []string{"$GITHUB_ACTOR"},
+	},
+	{
+		name:     "gitlab",
+		detect:   "GITLAB_CI",
+		pipeline: []string{"CI_PIPELINE_ID"},
+		job:      []string{"CI_JOB_NAME"},
+		// Retried GitLab jobs are new jobs with the same name.
+		attempt: "CI_JOB_ID",
+
+		repository: []string{"$CI_PROJECT_URL"},
+		branch:     []string{"$CI_MERGE_REQUEST_SOURCE_BRANCH_NAME", "$CI_COMMIT_REF_NAME"},
+		commit:     []string{"$CI_COMMIT_SHA"},
+		url:        []string{"$CI_PIPELINE_URL"},
+		actor:      []string{"$GITLAB_USER_LOGIN"},
+	},
+	{
+		name:     "buildkite",
+		detect:   "BUILDKITE",
+		pipeline: []string{"BUILDKITE_BUILD_ID"},
+		job:      []string{"BUILDKITE_STEP_ID"},
+		attempt:  "BUILDKITE_RETRY_COUNT",
+
+		repository: []string{"$BUILDKITE_REPO"},
+		branch:     []string{"$BUILDKITE_BRANCH"},
+		commit:     []string{"$BUILDKITE_COMMIT"},
+		url:        []string{"$BUILDKITE_BUILD_URL"},
+		actor:      []string{"$BUILDKITE_BUILD_CREATOR"},
+	},
+	{
+		name:     "drone",
+		detect:   "DRONE",
+		pipeline: []string{"DRONE_REPO"},
+		job:      []string{"DRONE_STAGE_NAME", "DRONE_STEP_NAME"},
+		// Restarting a Drone build creates a new build.
+		attempt: "DRONE_BUILD_NUMBER",
+
+		repository: []string{"$DRONE_REPO_LINK"},
+		branch:     []string{"$DRONE_SOURCE_BRANCH", "$DRONE_BRANCH"},
+		commit:     []string{"$DRONE_COMMIT_SHA"},
+		url:        []string{"$DRONE_BUILD_LINK"},
+		actor:      []string{"$DRONE_BUILD_TRIGGER"},
+	},
+	{
+		name:     "jenkins",
+		detect:   "JENKINS_URL",
+		pipeline: []string{"JOB_NAME"},
+		// Only set within the stages of declarative pipelines.
+		job:         []string{"STAGE_NAME"},
+		jobOptional: true,
+		// Rebuilding in Jenkins creates a new build.
+		attempt: "BUILD_NUMBER",
+
+		repository: []string{"$GIT_URL"},
+		branch:     []string{"$CHANGE_BRANCH", "$BRANCH_NAME", "$GIT_BRANCH"},
+		commit:     []string{"$GIT_COMMIT"},
+		url:        []string{"$BUILD_URL"},
+		// Set by the build user vars plugin.
+		actor: []string{"$BUILD_USER_ID"},
+	},
+}
+
+// Detect returns information about the CI job from the environment
+// variables set by the CI system. It returns false when no supported CI
+// system is detected, or when it doesn't identify the job. The details
+// about the repository and pipeline are set whenever a CI system is
+// detected.
+func Detect() (Info, bool) {
+	for _, p := range providers {
+		if os.Getenv(p.detect) == "" {
+			continue
+		}
+		info := Info{
+			Provider: p.name,
+			Pipeline: join(p.pipeline),
+			Job:      join(p.job),
+
+			Repository: expand(p.repository),
+			Branch:     expand(p.branch),
+			Commit:     expand(p.commit),
+			URL:        expand(p.url),
+			Actor:      expand(p.actor),
+		}
+		if p.attempt != "" {
+			info.Attempt = os.Getenv(p.attempt)
+		}
+		return info, info.Pipeline != "" && (info.Job != "" || p.jobOptional)
+	}
+	return Info{}, false
+}
+
+// join returns the non-empty values of the environment variables keys
+// separated by "/".
+func join(keys []string) string {
+	values := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if value := os.Getenv(key); value != "" {
+			values = append(values, value)
+		}
+	}
+	return strings.Join(values, "/")
+}
+
+// expand returns the first of candidates where all referenced environment
+// variables are set.
+func expand(candidates []string) string {
+	for _, candidate := range candidates {
+		unset := false
+		value := os.Expand(candidate, func(key string) string {
+			value := os.Getenv(key)
+			unset = unset || value == ""
+			return value
+		})
+		if !unset {
+			return value
+		}
+	}
+	return ""
+}
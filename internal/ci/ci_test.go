@@ -0,0 +1,53 @@
+package ci
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectRunID(t *testing.T) {
+	for name, tc := range map[string]struct {
+		env     map[string]string
+		runID   string
+		attempt string
+	}{
+		"github": {
+			env:     map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_RUN_ID": "42", "GITHUB_JOB": "test", "GITHUB_RUN_ATTEMPT": "2"},
+			runID:   "github/42/test",
+			attempt: "2",
+		},
+		"drone": {
+			env:     map[string]string{"DRONE": "true", "DRONE_REPO": "org/repo", "DRONE_STAGE_NAME": "default", "DRONE_STEP_NAME": "test", "DRONE_BUILD_NUMBER": "7"},
+			runID:   "drone/org/repo/default/test",
+			attempt: "7",
+		},
+		"jenkins": {
+			env:     map[string]string{"JENKINS_URL": "https://jenkins.example.com/", "JOB_NAME": "repo/main", "BUILD_NUMBER": "12"},
+			runID:   "jenkins/repo/main",
+			attempt: "12",
+		},
+		"jenkins stage": {
+			env:     map[string]string{"JENKINS_URL": "https://jenkins.example.com/", "JOB_NAME": "repo/main", "STAGE_NAME": "test", "BUILD_NUMBER": "12"},
+			runID:   "jenkins/repo/main/test",
+			attempt: "12",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, p := range providers {
+				t.Setenv(p.detect, "")
+				for _, key := range append(append(p.pipeline, p.job...), p.attempt) {
+					t.Setenv(key, "")
+				}
+			}
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+
+			info, ok := Detect()
+			require.True(t, ok)
+			require.Equal(t, tc.runID, info.RunID())
+			require.Equal(t, tc.attempt, info.Attempt)
+		})
+	}
+}
@@ -2,10 +2,15 @@ package console
 
 import (
 	"fmt"
+	"math"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/grafana/go-test-runner/internal/bench"
 	"github.com/grafana/go-test-runner/internal/cfg"
 	"github.com/grafana/go-test-runner/internal/grafana"
 	"github.com/grafana/go-test-runner/internal/tests"
@@ -15,14 +20,36 @@ type Console struct {
 	printLevel     cfg.PrintLevel
 	grafanaOptions cfg.GrafanaOptions
 	failedTests    map[string][]string
+	failedBuilds   map[tests.Key]string
+	artifacts      map[testKey]string
+	profiles       map[string][]string
 	traceID        string
+	// tracing is false when no spans are sent, so the trace ID only
+	// refers to logs.
+	tracing bool
+
+	pausedTests map[testKey]time.Time
+	paused      time.Duration
+
+	earliest time.Time
+	latest   time.Time
+}
+
+type testKey struct {
+	pkg  string
+	test string
 }
 
-func New(traceID string, opts cfg.ConsoleOptions, grafanaOpts cfg.GrafanaOptions) *Console {
+func New(traceID string, opts cfg.ConsoleOptions, grafanaOpts cfg.GrafanaOptions, tracingOpts cfg.TracingOptions) *Console {
 	return &Console{
 		printLevel:     opts.PrintLevel,
 		failedTests:    map[string][]string{},
+		failedBuilds:   map[tests.Key]string{},
+		artifacts:      map[testKey]string{},
+		profiles:       map[string][]string{},
+		pausedTests:    map[testKey]time.Time{},
 		traceID:        traceID,
+		tracing:        tracingOpts.Kind != cfg.TraceExporterNone,
 		grafanaOptions: grafanaOpts,
 	}
 }
@@ -30,44 +57,159 @@ func New(traceID string, opts cfg.ConsoleOptions, grafanaOpts cfg.GrafanaOptions
 func (c *Console) FailedTests() []string {
 	lines := []string{}
 	for pkg, ts := range c.failedTests {
-		sort.Strings(ts)
+		quoted := make([]string, len(ts))
 		for i, t := range ts {
-			ts[i] = strconv.Quote(t)
+			quoted[i] = strconv.Quote(t)
+			if dir, ok := c.artifacts[testKey{pkg: pkg, test: t}]; ok {
+				lines = append(lines, fmt.Sprintf("Artifacts of %s %s: %s", pkg, t, dir))
+			}
 		}
+		sort.Strings(quoted)
 
-		lines = append(lines, fmt.Sprintf("Failures in %s: [%s]", pkg, strings.Join(ts, ", ")))
+		lines = append(lines, fmt.Sprintf("Failures in %s: [%s]", pkg, strings.Join(quoted, ", ")))
+	}
+	for key, importPath := range c.failedBuilds {
+		pkg := packageName(key)
+		// Packages built for a test binary have it appended, such as
+		// "pkg [pkg.test]".
+		importPath, _, _ = strings.Cut(importPath, " [")
+		if key.Package == importPath {
+			lines = append(lines, fmt.Sprintf("Build failure in %s", pkg))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("Build failure in %s: %s failed to build", pkg, importPath))
 	}
 	sort.Strings(lines)
 	return lines
 }
 
 func (c *Console) Handle(e tests.Event) error {
+	if ts := e.Timestamp; !ts.IsZero() {
+		if c.earliest.IsZero() || ts.Before(c.earliest) {
+			c.earliest = ts
+		}
+		if ts.After(c.latest) {
+			c.latest = ts
+		}
+	}
+
 	switch ev := e.Payload.(type) {
 	case tests.Print:
 		if c.printLevel == cfg.PrintLevelRaw {
 			fmt.Print(ev.Line)
 		}
+	case tests.RawOutput:
+		if c.printLevel == cfg.PrintLevelRaw {
+			fmt.Print(ev.Line)
+		}
+	case tests.BuildOutput:
+		if c.printLevel == cfg.PrintLevelRaw {
+			fmt.Print(ev.Line)
+		}
 	case tests.StateChange:
+		key := tests.Key{Shard: e.Shard, Package: e.Package}
+		pkg := packageName(key)
 		if e.Test != "" && ev.NewState == tests.StateFailed {
-			c.failedTests[e.Package] = append(c.failedTests[e.Package], e.Test)
+			c.failedTests[pkg] = append(c.failedTests[pkg], e.Test)
+		}
+		if ev.NewState == tests.StateBuildFailed {
+			c.failedBuilds[key] = ev.FailedBuild
+		}
+		if e.Test == "" && len(ev.Profiles) > 0 {
+			c.profiles[pkg] = ev.Profiles
+		}
+	case tests.Artifacts:
+		c.artifacts[newTestKey(e)] = ev.Path()
+	case tests.Paused:
+		c.pausedTests[newTestKey(e)] = e.Timestamp
+	case tests.Continued:
+		key := newTestKey(e)
+		if pausedAt, ok := c.pausedTests[key]; ok {
+			c.paused += e.Timestamp.Sub(pausedAt)
+			delete(c.pausedTests, key)
 		}
 	}
 
 	return nil
 }
 
+// packageName returns the package along with its shard if any, as the
+// same package can run in several shards.
+func packageName(key tests.Key) string {
+	if key.Shard == "" {
+		return key.Package
+	}
+	return key.Package + " (" + key.Shard + ")"
+}
+
+func newTestKey(e tests.Event) testKey {
+	return testKey{pkg: packageName(tests.Key{Shard: e.Shard, Package: e.Package}), test: e.Test}
+}
+
+// BenchComparison prints a table comparing benchmarks against a baseline.
+func (c *Console) BenchComparison(cs []bench.Comparison) {
+	if len(cs) == 0 {
+		fmt.Println("No benchmarks in common with the baseline")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "package\tbenchmark\tunit\tbaseline\tcurrent\tdelta")
+	for _, cmp := range cs {
+		delta := "~"
+		if cmp.Significant {
+			delta = fmt.Sprintf("%+.2f%%", cmp.Delta)
+		}
+		delta = fmt.Sprintf("%s (p=%.3f n=%d+%d)", delta, cmp.P, cmp.Old.N, cmp.New.N)
+		if cmp.Regression {
+			delta += " REGRESSION"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			cmp.Package, cmp.Name, cmp.Unit, formatSummary(cmp.Old), formatSummary(cmp.New), delta)
+	}
+	w.Flush()
+}
+
+func formatSummary(s bench.Summary) string {
+	if s.Mean == 0 || s.N < 2 {
+		return strconv.FormatFloat(s.Mean, 'g', 4, 64)
+	}
+	return fmt.Sprintf("%s ± %.0f%%", strconv.FormatFloat(s.Mean, 'g', 4, 64), s.StdDev/math.Abs(s.Mean)*100)
+}
+
+// Profiles returns a line per package with the paths of its profiles.
+func (c *Console) Profiles() []string {
+	lines := make([]string, 0, len(c.profiles))
+	for pkg, paths := range c.profiles {
+		lines = append(lines, fmt.Sprintf("Profiles of %s: %s", pkg, strings.Join(paths, ", ")))
+	}
+	sort.Strings(lines)
+	return lines
+}
+
 func (c *Console) Stop() {
+	for _, line := range c.Profiles() {
+		fmt.Println(line)
+	}
 	for _, line := range c.FailedTests() {
 		fmt.Println(line)
 	}
 
-	fmt.Println("TraceID: ", c.traceID)
+	if c.paused > 0 {
+		fmt.Println("Time spent waiting for parallel tests: ", c.paused.Round(time.Millisecond))
+	}
+
+	if c.tracing {
+		fmt.Println("TraceID: ", c.traceID)
+	}
 	if c.grafanaOptions.URL != "" {
 		fmt.Println(grafana.LokiExploreLink{
 			GrafanaURL:    c.grafanaOptions.URL,
 			DataSource:    c.grafanaOptions.LokiDatasource,
 			DataSourceUID: c.grafanaOptions.LokiDatasourceUID,
 			TraceID:       c.traceID,
+			From:          c.earliest,
+			To:            c.latest,
 		})
 	}
 }
@@ -0,0 +1,41 @@
+package console
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/grafana/go-test-runner/internal/cfg"
+	"github.com/grafana/go-test-runner/internal/tests"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailedBuilds(t *testing.T) {
+	c := New("", cfg.ConsoleOptions{PrintLevel: cfg.PrintLevelNone}, cfg.GrafanaOptions{}, cfg.TracingOptions{})
+	j := tests.NewGoJSON(strings.NewReader(strings.Join([]string{
+		`{"ImportPath":"example.com/a [example.com/a.test]","Action":"build-output","Output":"# example.com/a [example.com/a.test]\n"}`,
+		`{"ImportPath":"example.com/a [example.com/a.test]","Action":"build-output","Output":"a_test.go:3:1: syntax error\n"}`,
+		`{"ImportPath":"example.com/a [example.com/a.test]","Action":"build-fail"}`,
+		`{"Time":"2023-03-01T10:00:00Z","Action":"start","Package":"example.com/a"}`,
+		`{"Time":"2023-03-01T10:00:00Z","Action":"fail","Package":"example.com/a","Elapsed":0,"FailedBuild":"example.com/a [example.com/a.test]"}`,
+		`{"ImportPath":"example.com/dep [example.com/b.test]","Action":"build-fail"}`,
+		`{"Time":"2023-03-01T10:00:00Z","Action":"start","Package":"example.com/b"}`,
+		`{"Time":"2023-03-01T10:00:00Z","Action":"fail","Package":"example.com/b","Elapsed":0,"FailedBuild":"example.com/dep [example.com/b.test]"}`,
+	}, "\n")))
+	for {
+		events, err := j.ReadLine()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		for _, e := range events {
+			require.NoError(t, c.Handle(e))
+		}
+	}
+
+	require.Equal(t, []string{
+		"Build failure in example.com/a",
+		"Build failure in example.com/b: example.com/dep failed to build",
+	}, c.FailedTests())
+}
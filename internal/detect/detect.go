@@ -0,0 +1,224 @@
+package detect
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/go-test-runner/internal/cfg"
+	"github.com/grafana/go-test-runner/internal/ci"
+	"github.com/grafana/go-test-runner/internal/git"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Attribute is a property of the environment go-test-runner runs in. It is
+// added to the resource of the spans as Key and to the Loki lines as Field.
+type Attribute struct {
+	Key   attribute.Key
+	Field string
+	Value string
+}
+
+// Detect returns the attributes detected from the sources enabled in
+// opts. The CI system's commit and branch take precedence over the ones
+// read from the working tree, since CI systems might check out other
+// commits such as merges of pull requests. The working tree's attributes
+// are only used when it has the CI system's commit. Attributes from
+// sources which fail are left out and the errors are returned.
+func Detect(opts cfg.ResourceOptions) ([]Attribute, error) {
+	var attrs []Attribute
+	var errs []error
+	if opts.CI {
+		attrs = append(attrs, CI()...)
+	}
+	if opts.Git {
+		gitAttrs, err := Git()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read git repository: %w", err))
+		}
+		// The author, time and changes of another commit would be
+		// attributed to the CI system's commit.
+		if commit := value(attrs, "vcs.ref.head.revision"); commit != "" && commit != value(gitAttrs, "vcs.ref.head.revision") {
+			gitAttrs = nil
+		}
+		attrs = append(attrs, gitAttrs...)
+	}
+	if opts.Go {
+		goAttrs, err := Go()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to detect Go environment: %w", err))
+		}
+		attrs = append(attrs, goAttrs...)
+	}
+	return unique(attrs), errors.Join(errs...)
+}
+
+// CI returns the attributes of the CI job, if go-test-runner runs in a
+// supported CI system.
+func CI() []Attribute {
+	info, _ := ci.Detect()
+	if info.Provider == "" {
+		return nil
+	}
+	// The vcs and cicd keys are from the OpenTelemetry semantic
+	// conventions.
+	return nonEmpty([]Attribute{
+		{Key: "ci.provider", Field: "ciProvider", Value: info.Provider},
+		{Key: "vcs.repository.url.full", Field: "repository", Value: info.Repository},
+		{Key: "vcs.ref.head.name", Field: "branch", Value: info.Branch},
+		{Key: "vcs.ref.head.revision", Field: "commit", Value: info.Commit},
+		{Key: "cicd.pipeline.run.url.full", Field: "pipelineURL", Value: info.URL},
+		{Key: "ci.actor", Field: "actor", Value: info.Actor},
+	})
+}
+
+// Git returns the attributes of the commit checked out in the working
+// tree of the current directory, if it's in a git repository. If only
+// checking the working tree for changes fails, the other attributes are
+// returned with the error. Working trees with converted files, which
+// can't be checked, have no dirty attribute either.
+func Git() ([]Attribute, error) {
+	info, err := git.Read(".")
+	if errors.Is(err, git.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil && !errors.Is(err, git.ErrDirtyUnknown) {
+		return nil, err
+	}
+	attrs := []Attribute{
+		{Key: "vcs.ref.head.revision", Field: "commit", Value: info.Commit},
+		{Key: "vcs.ref.head.name", Field: "branch", Value: info.Branch},
+	}
+	if info.Commit != "" {
+		if err == nil {
+			attrs = append(attrs, Attribute{Key: "git.dirty", Field: "dirty", Value: strconv.FormatBool(info.Dirty)})
+		}
+		attrs = append(attrs,
+			Attribute{Key: "git.commit.author", Field: "commitAuthor", Value: info.Author},
+			Attribute{Key: "git.commit.time", Field: "commitTime", Value: info.Time.Format(time.RFC3339)},
+		)
+	}
+	if errors.Is(err, git.ErrConverted) {
+		err = nil
+	}
+	return nonEmpty(attrs), err
+}
+
+// Go returns the attributes of the Go toolchain and the module in the
+// current directory, using `go env`.
+func Go() ([]Attribute, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("go", "env", "-json", "GOVERSION", "GOOS", "GOARCH", "GOFLAGS", "GOMOD")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	var env struct {
+		GOVERSION string
+		GOOS      string
+		GOARCH    string
+		GOFLAGS   string
+		GOMOD     string
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &env); err != nil {
+		return nil, err
+	}
+
+	module := ""
+	// GOMOD is os.DevNull when module mode is on outside of a module.
+	if env.GOMOD != "" && env.GOMOD != os.DevNull {
+		var err error
+		if module, err = ModulePath(env.GOMOD); err != nil {
+			return nil, err
+		}
+	}
+	return nonEmpty([]Attribute{
+		{Key: "go.version", Field: "goVersion", Value: env.GOVERSION},
+		{Key: "go.os", Field: "goos", Value: env.GOOS},
+		{Key: "go.arch", Field: "goarch", Value: env.GOARCH},
+		{Key: "go.flags", Field: "goflags", Value: env.GOFLAGS},
+		{Key: "go.module", Field: "module", Value: module},
+	}), nil
+}
+
+// ModulePath returns the module path declared in the go.mod file filename.
+func ModulePath(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("no module directive in " + filename)
+}
+
+// Fields adds the attributes to fields, unless fields already has a value
+// for them.
+func Fields(fields cfg.Tags, attrs []Attribute) {
+	for _, attr := range attrs {
+		if _, ok := fields[attr.Field]; !ok {
+			fields[attr.Field] = attr.Value
+		}
+	}
+}
+
+// KeyValues returns the attributes as span resource attributes.
+func KeyValues(attrs []Attribute) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		kvs = append(kvs, attr.Key.String(attr.Value))
+	}
+	return kvs
+}
+
+// value returns the value of the attribute key, or an empty string if
+// there is none.
+func value(attrs []Attribute, key attribute.Key) string {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// unique removes the attributes with the same key as an earlier one.
+func unique(attrs []Attribute) []Attribute {
+	seen := map[attribute.Key]struct{}{}
+	kept := attrs[:0]
+	for _, attr := range attrs {
+		if _, ok := seen[attr.Key]; !ok {
+			seen[attr.Key] = struct{}{}
+			kept = append(kept, attr)
+		}
+	}
+	return kept
+}
+
+func nonEmpty(attrs []Attribute) []Attribute {
+	kept := attrs[:0]
+	for _, attr := range attrs {
+		if attr.Value != "" {
+			kept = append(kept, attr)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,432 @@
+// Package git reads the state of a git working tree directly from its
+// .git directory, without running git.
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when there is no git repository.
+var ErrNotFound = errors.New("not in a git repository")
+
+// ErrDirtyUnknown is returned by Read when the rest of the information
+// was read, but not whether the working tree has changes.
+var ErrDirtyUnknown = errors.New("failed to check for changes")
+
+// ErrConverted is returned by Dirty when files are converted between the
+// index and the working tree by core.autocrlf or clean and smudge
+// filters, such as Git LFS. Conversions can't be told apart from
+// changes without running them.
+var ErrConverted = errors.New("files are converted by core.autocrlf or filters")
+
+// Info describes the commit checked out in a working tree.
+type Info struct {
+	// Commit is the hash of the checked out commit, empty when the
+	// branch has no commits yet.
+	Commit string
+	// Branch is the checked out branch, empty when HEAD is detached.
+	Branch string
+	// Dirty is true when there are changes to tracked files, staged or
+	// not, compared to the checked out commit.
+	Dirty bool
+	// Author is the author of the commit on the format "Name <email>".
+	Author string
+	// Time is when the commit was committed.
+	Time time.Time
+}
+
+// Read returns information about the working tree dir is in.
+func Read(dir string) (Info, error) {
+	r, err := Open(dir)
+	if err != nil {
+		return Info{}, err
+	}
+	defer r.Close()
+
+	head, branch, err := r.Head()
+	if err != nil {
+		return Info{}, err
+	}
+	info := Info{Branch: branch}
+	var c *Commit
+	if head != (Hash{}) {
+		commit, err := r.Commit(head)
+		if err != nil {
+			return Info{}, err
+		}
+		c = &commit
+		info.Commit = head.String()
+		info.Author = commit.Author
+		info.Time = commit.Time
+	}
+	info.Dirty, err = r.Dirty(c)
+	if err != nil {
+		return info, fmt.Errorf("%w: %w", ErrDirtyUnknown, err)
+	}
+	return info, nil
+}
+
+// Hash is the SHA-1 hash identifying an object.
+type Hash [20]byte
+
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+func parseHash(s string) (Hash, error) {
+	var h Hash
+	if len(s) != hex.EncodedLen(len(h)) {
+		return h, fmt.Errorf("invalid object hash %q", s)
+	}
+	_, err := hex.Decode(h[:], []byte(s))
+	return h, err
+}
+
+// Repository is a git repository with a working tree.
+type Repository struct {
+	// dir is the git directory, which is separate from common for
+	// linked worktrees.
+	dir      string
+	common   string
+	workTree string
+	objects  []string
+	packs    []*pack
+	// packsRead is true once the packs have been opened.
+	packsRead bool
+	config    config
+}
+
+// Open returns the repository of the working tree dir is in, looking for
+// a .git directory or file in dir and its parents.
+func Open(dir string) (*Repository, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		gitPath := filepath.Join(dir, ".git")
+		fi, err := os.Stat(gitPath)
+		switch {
+		case err == nil && fi.IsDir():
+			return newRepository(gitPath, dir)
+		case err == nil:
+			// Linked worktrees and submodules have a file pointing to
+			// their git directory.
+			gitDir, err := readGitFile(gitPath)
+			if err != nil {
+				return nil, err
+			}
+			return newRepository(gitDir, dir)
+		case !errors.Is(err, os.ErrNotExist):
+			return nil, err
+		}
+		if filepath.Dir(dir) == dir {
+			return nil, ErrNotFound
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func readGitFile(filename string) (string, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(b)), "gitdir: ")
+	if !ok {
+		return "", fmt.Errorf("invalid git file %s", filename)
+	}
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(filename), gitDir)
+	}
+	return gitDir, nil
+}
+
+func newRepository(dir, workTree string) (*Repository, error) {
+	common := dir
+	if b, err := os.ReadFile(filepath.Join(dir, "commondir")); err == nil {
+		common = strings.TrimSpace(string(b))
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(dir, common)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	c, err := readConfig(append(globalConfigs(), filepath.Join(common, "config"))...)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Repository{dir: dir, common: common, workTree: workTree, config: c}
+	objects := filepath.Join(common, "objects")
+	r.objects = append([]string{objects}, alternates(objects)...)
+	return r, nil
+}
+
+// config is the configuration affecting how the working tree is read.
+type config struct {
+	// fileMode is false when the executable bit of files is ignored.
+	fileMode bool
+	// autocrlf and filters are true when files are converted between
+	// the index and the working tree, by converting line endings or
+	// by clean and smudge filters.
+	autocrlf bool
+	filters  bool
+}
+
+// globalConfigs returns the user's configuration files, in the order
+// git reads them.
+func globalConfigs() []string {
+	if filename := os.Getenv("GIT_CONFIG_GLOBAL"); filename != "" {
+		return []string{filename}
+	}
+	var filenames []string
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		filenames = append(filenames, filepath.Join(dir, "git", "config"))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		if os.Getenv("XDG_CONFIG_HOME") == "" {
+			filenames = append(filenames, filepath.Join(home, ".config", "git", "config"))
+		}
+		filenames = append(filenames, filepath.Join(home, ".gitconfig"))
+	}
+	return filenames
+}
+
+// readConfig reads the configuration files, where later files take
+// precedence. It returns an error if the repository uses a format which
+// isn't supported.
+func readConfig(filenames ...string) (config, error) {
+	c := config{fileMode: true}
+	for _, filename := range filenames {
+		f, err := os.Open(filename)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return config{}, err
+		}
+		err = c.read(f)
+		f.Close()
+		if err != nil {
+			return config{}, fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+	}
+	return c, nil
+}
+
+func (c *config) read(r io.Reader) error {
+	section := ""
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			// Subsections such as [filter "lfs"] are named in quotes.
+			name, _, _ := strings.Cut(strings.Trim(line, "[]"), " ")
+			section = strings.ToLower(name)
+			continue
+		}
+		rawKey, rawValue, hasValue := strings.Cut(line, "=")
+		key := strings.ToLower(strings.TrimSpace(rawKey))
+		value := strings.ToLower(strings.Trim(strings.TrimSpace(rawValue), `"`))
+		switch {
+		case key == "objectformat" && value != "sha1":
+			return fmt.Errorf("unsupported git object format %s", value)
+		case key == "refstorage" && value != "files":
+			return fmt.Errorf("unsupported git ref storage %s", value)
+		case section == "core" && key == "filemode":
+			c.fileMode = !hasValue || configBool(value)
+		case section == "core" && key == "autocrlf":
+			c.autocrlf = value == "input" || !hasValue || configBool(value)
+		case section == "filter" && (key == "clean" || key == "smudge" || key == "process"):
+			c.filters = true
+		}
+	}
+	return scanner.Err()
+}
+
+// configBool reports whether a configuration value is true.
+func configBool(value string) bool {
+	switch value {
+	case "true", "yes", "on", "1":
+		return true
+	}
+	return false
+}
+
+// alternates returns the object directories listed in the alternates
+// file of the objects directory.
+func alternates(objects string) []string {
+	b, err := os.ReadFile(filepath.Join(objects, "info", "alternates"))
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(objects, line)
+		}
+		dirs = append(dirs, line)
+	}
+	return dirs
+}
+
+// Close closes the pack files opened by the repository.
+func (r *Repository) Close() error {
+	var errs []error
+	for _, p := range r.packs {
+		errs = append(errs, p.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Head returns the checked out commit and branch. The hash is zero when
+// the branch has no commits yet, and the branch is empty when HEAD is
+// detached.
+func (r *Repository) Head() (Hash, string, error) {
+	ref, branch := "HEAD", ""
+	// Symbolic refs can point to other symbolic refs.
+	for i := 0; i < 5; i++ {
+		value, err := r.ref(ref)
+		if errors.Is(err, os.ErrNotExist) && ref != "HEAD" {
+			return Hash{}, branch, nil
+		}
+		if err != nil {
+			return Hash{}, "", err
+		}
+		target, ok := strings.CutPrefix(value, "ref: ")
+		if !ok {
+			h, err := parseHash(value)
+			return h, branch, err
+		}
+		if b, ok := strings.CutPrefix(target, "refs/heads/"); ok && ref == "HEAD" {
+			branch = b
+		}
+		ref = target
+	}
+	return Hash{}, "", fmt.Errorf("too many levels of symbolic refs from HEAD")
+}
+
+// ref returns the value of the named ref, which is either a hash or
+// "ref: " followed by the name of another ref.
+func (r *Repository) ref(name string) (string, error) {
+	// HEAD and other per-worktree refs are in the worktree's git
+	// directory, the rest are shared.
+	for _, dir := range []string{r.dir, r.common} {
+		b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err == nil {
+			return strings.TrimSpace(string(b)), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+	return r.packedRef(name)
+}
+
+func (r *Repository) packedRef(name string) (string, error) {
+	f, err := os.Open(filepath.Join(r.common, "packed-refs"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Comments and peeled tags start with # and ^.
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+			continue
+		}
+		if hash, ref, ok := strings.Cut(line, " "); ok && ref == name {
+			return hash, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("ref %s: %w", name, os.ErrNotExist)
+}
+
+// Commit is the parts of a commit object used by go-test-runner.
+type Commit struct {
+	Tree   Hash
+	Author string
+	Time   time.Time
+}
+
+// Commit reads the commit object h.
+func (r *Repository) Commit(h Hash) (Commit, error) {
+	typ, data, err := r.object(h)
+	if err != nil {
+		return Commit{}, err
+	}
+	if typ != objectCommit {
+		return Commit{}, fmt.Errorf("object %s is a %s, not a commit", h, typ)
+	}
+
+	var c Commit
+	for len(data) > 0 {
+		line, rest, _ := bytes.Cut(data, []byte("\n"))
+		data = rest
+		// The headers end at the first empty line.
+		if len(line) == 0 {
+			break
+		}
+		key, value, _ := strings.Cut(string(line), " ")
+		switch key {
+		case "tree":
+			c.Tree, err = parseHash(value)
+		case "author":
+			c.Author, _, err = parseSignature(value)
+		case "committer":
+			_, c.Time, err = parseSignature(value)
+		}
+		if err != nil {
+			return Commit{}, fmt.Errorf("invalid commit %s: %w", h, err)
+		}
+	}
+	return c, nil
+}
+
+// parseSignature parses the "Name <email> timestamp timezone" of the
+// author and committer of commits.
+func parseSignature(s string) (string, time.Time, error) {
+	end := strings.LastIndex(s, ">")
+	if end == -1 {
+		return "", time.Time{}, fmt.Errorf("invalid signature %q", s)
+	}
+	fields := strings.Fields(s[end+1:])
+	if len(fields) != 2 || len(fields[1]) != 5 {
+		return "", time.Time{}, fmt.Errorf("invalid signature %q", s)
+	}
+	seconds, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	hours, hoursErr := strconv.Atoi(fields[1][1:3])
+	minutes, minutesErr := strconv.Atoi(fields[1][3:])
+	if err := errors.Join(hoursErr, minutesErr); err != nil {
+		return "", time.Time{}, err
+	}
+	offset := hours*60*60 + minutes*60
+	if fields[1][0] == '-' {
+		offset = -offset
+	}
+	zone := time.FixedZone(fields[1], offset)
+	return s[:end+1], time.Unix(seconds, 0).In(zone), nil
+}
@@ -0,0 +1,232 @@
+package git
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// gitRepo creates a repository in a temporary directory using the git
+// command, and returns a function running git in it.
+func gitRepo(t *testing.T) (string, func(args ...string) string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	// Read uses the global configuration like git does.
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	dir := t.TempDir()
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_CONFIG_GLOBAL="+os.DevNull,
+			"GIT_CONFIG_NOSYSTEM=1",
+			"GIT_AUTHOR_NAME=Author",
+			"GIT_AUTHOR_EMAIL=author@example.com",
+			"GIT_AUTHOR_DATE=2023-03-01T10:00:00+01:00",
+			"GIT_COMMITTER_NAME=Committer",
+			"GIT_COMMITTER_EMAIL=committer@example.com",
+			"GIT_COMMITTER_DATE=2023-03-02T10:00:00-05:30",
+		)
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, "git %s: %s", strings.Join(args, " "), out)
+		return strings.TrimSpace(string(out))
+	}
+	run("init", "-q", "-b", "main")
+	return dir, run
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+}
+
+func TestRead(t *testing.T) {
+	dir, git := gitRepo(t)
+
+	info, err := Read(dir)
+	require.NoError(t, err)
+	require.Equal(t, Info{Branch: "main"}, info)
+
+	writeFile(t, dir, "a.txt", "a\n")
+	writeFile(t, dir, "sub/b.txt", "b\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "first")
+
+	info, err = Read(filepath.Join(dir, "sub"))
+	require.NoError(t, err)
+	require.Equal(t, git("rev-parse", "HEAD"), info.Commit)
+	require.Equal(t, "main", info.Branch)
+	require.False(t, info.Dirty)
+	require.Equal(t, "Author <author@example.com>", info.Author)
+	require.Equal(t, "2023-03-02T10:00:00-05:30", info.Time.Format(time.RFC3339))
+
+	git("checkout", "-q", "--detach")
+	info, err = Read(dir)
+	require.NoError(t, err)
+	require.Equal(t, git("rev-parse", "HEAD"), info.Commit)
+	require.Empty(t, info.Branch)
+
+	_, err = Read(t.TempDir())
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestReadDirty(t *testing.T) {
+	dir, git := gitRepo(t)
+	writeFile(t, dir, "a.txt", "a\n")
+	writeFile(t, dir, "b.txt", "b\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "first")
+
+	dirty := func() bool {
+		t.Helper()
+		info, err := Read(dir)
+		require.NoError(t, err)
+		return info.Dirty
+	}
+	require.False(t, dirty())
+
+	// Same size, so the contents are compared.
+	writeFile(t, dir, "a.txt", "c\n")
+	require.True(t, dirty())
+	writeFile(t, dir, "a.txt", "a\n")
+	require.False(t, dirty())
+
+	writeFile(t, dir, "untracked.txt", "untracked\n")
+	require.False(t, dirty())
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "b.txt")))
+	require.True(t, dirty())
+	git("checkout", "b.txt")
+	require.False(t, dirty())
+
+	writeFile(t, dir, "b.txt", "staged\n")
+	git("add", "b.txt")
+	require.True(t, dirty())
+	git("commit", "-q", "-m", "second")
+	require.False(t, dirty())
+
+	// Without the cache tree, the index is compared to the commit's
+	// tree.
+	git("update-index", "--index-version", "4")
+	git("rm", "-q", "--cached", "b.txt")
+	require.True(t, dirty())
+	git("reset", "-q")
+	require.False(t, dirty())
+}
+
+func TestReadPacked(t *testing.T) {
+	dir, git := gitRepo(t)
+	content := strings.Repeat("line of the file\n", 500)
+	for i := 0; i < 5; i++ {
+		content += fmt.Sprintf("change %d\n", i)
+		writeFile(t, dir, "file.txt", content)
+		writeFile(t, dir, fmt.Sprintf("dir/%d.txt", i), content)
+		git("add", ".")
+		git("commit", "-q", "-m", fmt.Sprintf("commit %d", i))
+	}
+	git("gc", "-q", "--aggressive")
+	require.NoFileExists(t, filepath.Join(dir, ".git", "refs", "heads", "main"))
+
+	info, err := Read(dir)
+	require.NoError(t, err)
+	require.Equal(t, git("rev-parse", "HEAD"), info.Commit)
+	require.Equal(t, "main", info.Branch)
+	require.False(t, info.Dirty)
+
+	r, err := Open(dir)
+	require.NoError(t, err)
+	defer r.Close()
+	for _, line := range strings.Split(git("rev-list", "--objects", "--all"), "\n") {
+		rawHash, _, _ := strings.Cut(line, " ")
+		h, err := parseHash(rawHash)
+		require.NoError(t, err)
+		typ, data, err := r.object(h)
+		require.NoError(t, err)
+		require.Equal(t, h, Hash(sha1.Sum(append([]byte(fmt.Sprintf("%s %d\x00", typ, len(data))), data...))))
+	}
+}
+
+func TestReadWorktree(t *testing.T) {
+	dir, git := gitRepo(t)
+	writeFile(t, dir, "a.txt", "a\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "first")
+	worktree := filepath.Join(t.TempDir(), "worktree")
+	git("worktree", "add", "-q", "-b", "feature", worktree)
+
+	info, err := Read(worktree)
+	require.NoError(t, err)
+	require.Equal(t, git("rev-parse", "HEAD"), info.Commit)
+	require.Equal(t, "feature", info.Branch)
+	require.False(t, info.Dirty)
+}
+
+func TestReadSplitIndex(t *testing.T) {
+	dir, git := gitRepo(t)
+	writeFile(t, dir, "a.txt", "a\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "first")
+	git("update-index", "--split-index")
+
+	info, err := Read(dir)
+	require.ErrorIs(t, err, ErrDirtyUnknown)
+	require.Equal(t, git("rev-parse", "HEAD"), info.Commit)
+	require.Equal(t, "main", info.Branch)
+	require.Equal(t, "Author <author@example.com>", info.Author)
+}
+
+func TestReadFileMode(t *testing.T) {
+	dir, git := gitRepo(t)
+	writeFile(t, dir, "a.sh", "a\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "first")
+	require.NoError(t, os.Chmod(filepath.Join(dir, "a.sh"), 0o755))
+
+	info, err := Read(dir)
+	require.NoError(t, err)
+	require.True(t, info.Dirty)
+
+	git("config", "core.fileMode", "false")
+	require.Empty(t, git("status", "--porcelain"))
+	info, err = Read(dir)
+	require.NoError(t, err)
+	require.False(t, info.Dirty)
+}
+
+func TestReadConverted(t *testing.T) {
+	for name, config := range map[string][]string{
+		"autocrlf": {"core.autocrlf", "true"},
+		"filter":   {"filter.lfs.clean", "git-lfs clean -- %f"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, git := gitRepo(t)
+			writeFile(t, dir, "a.txt", "a\n")
+			git("add", ".")
+			git("commit", "-q", "-m", "first")
+			git("config", config[0], config[1])
+
+			info, err := Read(dir)
+			require.ErrorIs(t, err, ErrDirtyUnknown)
+			require.ErrorIs(t, err, ErrConverted)
+			require.Equal(t, git("rev-parse", "HEAD"), info.Commit)
+
+			// Staged changes are found without the working tree.
+			git("rm", "-q", "--cached", "a.txt")
+			info, err = Read(dir)
+			require.NoError(t, err)
+			require.True(t, info.Dirty)
+		})
+	}
+}
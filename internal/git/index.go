@@ -0,0 +1,364 @@
+package git
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Modes of index and tree entries.
+const (
+	modeType    = 0o170000
+	modeDir     = 0o040000
+	modeFile    = 0o100000
+	modeSymlink = 0o120000
+	modeGitlink = 0o160000
+)
+
+type indexEntry struct {
+	name      string
+	mtimeSec  uint32
+	mtimeNsec uint32
+	mode      uint32
+	size      uint32
+	hash      Hash
+	// stage is non-zero for the sides of a merge conflict.
+	stage        int
+	skipWorktree bool
+	intentToAdd  bool
+}
+
+type index struct {
+	entries []indexEntry
+	// tree is the tree of the index from the cache tree extension, if
+	// the extension is valid for the whole index.
+	tree *Hash
+	// modTime is when the index was written. Files modified at the
+	// same time or later might have changed without their size or
+	// modification time changing.
+	modTime time.Time
+}
+
+// readIndex parses the index file, see gitformat-index(5).
+func readIndex(filename string) (index, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return index{}, err
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return index{}, err
+	}
+	idx := index{modTime: fi.ModTime()}
+
+	// The header is followed by the entries, extensions and a checksum.
+	if len(data) < 12+len(Hash{}) || !bytes.Equal(data[:4], []byte("DIRC")) {
+		return index{}, fmt.Errorf("invalid index %s", filename)
+	}
+	version := binary.BigEndian.Uint32(data[4:])
+	if version < 2 || version > 4 {
+		return index{}, fmt.Errorf("unsupported index version %d", version)
+	}
+	n := int(binary.BigEndian.Uint32(data[8:]))
+	rest := data[12 : len(data)-len(Hash{})]
+
+	errTruncated := fmt.Errorf("truncated index %s", filename)
+	previous := ""
+	for i := 0; i < n; i++ {
+		const statSize = 10 * 4
+		if len(rest) < statSize+len(Hash{})+2 {
+			return index{}, errTruncated
+		}
+		e := indexEntry{
+			mtimeSec:  binary.BigEndian.Uint32(rest[8:]),
+			mtimeNsec: binary.BigEndian.Uint32(rest[12:]),
+			mode:      binary.BigEndian.Uint32(rest[24:]),
+			size:      binary.BigEndian.Uint32(rest[36:]),
+		}
+		copy(e.hash[:], rest[statSize:])
+		flags := binary.BigEndian.Uint16(rest[statSize+len(Hash{}):])
+		e.stage = int(flags>>12) & 3
+		entryLen := statSize + len(Hash{}) + 2
+		if flags&0x4000 != 0 {
+			if version < 3 || len(rest) < entryLen+2 {
+				return index{}, fmt.Errorf("invalid index entry %d in %s", i, filename)
+			}
+			extended := binary.BigEndian.Uint16(rest[entryLen:])
+			e.skipWorktree = extended&0x4000 != 0
+			e.intentToAdd = extended&0x2000 != 0
+			entryLen += 2
+		}
+
+		if version == 4 {
+			// The name is the previous name with a number of bytes
+			// removed from its end and a suffix appended.
+			strip, m := offsetVarint(rest[entryLen:])
+			if m == 0 || strip > len(previous) {
+				return index{}, errTruncated
+			}
+			entryLen += m
+			end := bytes.IndexByte(rest[entryLen:], 0)
+			if end == -1 {
+				return index{}, errTruncated
+			}
+			e.name = previous[:len(previous)-strip] + string(rest[entryLen:entryLen+end])
+			entryLen += end + 1
+		} else {
+			end := bytes.IndexByte(rest[entryLen:], 0)
+			if end == -1 {
+				return index{}, errTruncated
+			}
+			e.name = string(rest[entryLen : entryLen+end])
+			// Entries are padded with 1-8 NUL bytes to a multiple
+			// of 8 bytes.
+			entryLen = (entryLen + end + 8) &^ 7
+			if entryLen > len(rest) {
+				return index{}, errTruncated
+			}
+		}
+		previous = e.name
+		idx.entries = append(idx.entries, e)
+		rest = rest[entryLen:]
+	}
+
+	for len(rest) >= 8 {
+		signature := string(rest[:4])
+		size := int(binary.BigEndian.Uint32(rest[4:]))
+		if len(rest) < 8+size {
+			return index{}, errTruncated
+		}
+		ext := rest[8 : 8+size]
+		switch signature {
+		case "TREE":
+			idx.tree = cacheTreeRoot(ext)
+		case "link":
+			return index{}, fmt.Errorf("split index %s is not supported", filename)
+		}
+		rest = rest[8+size:]
+	}
+	return idx, nil
+}
+
+// offsetVarint reads the variable length integers used for offsets in
+// packs and name prefixes in version 4 indexes. It returns the number
+// of bytes read, or zero if p is too short.
+func offsetVarint(p []byte) (int, int) {
+	if len(p) == 0 {
+		return 0, 0
+	}
+	value := int(p[0] & 0x7f)
+	i := 0
+	for p[i]&0x80 != 0 {
+		i++
+		if i == len(p) {
+			return 0, 0
+		}
+		value = (value+1)<<7 | int(p[i]&0x7f)
+	}
+	return value, i + 1
+}
+
+// cacheTreeRoot returns the tree of the root directory in a cache tree
+// extension, or nil if it has been invalidated by changes to the index.
+func cacheTreeRoot(ext []byte) *Hash {
+	// The root comes first, with an empty path, the number of entries it
+	// covers and the number of subtrees.
+	if len(ext) == 0 || ext[0] != 0 {
+		return nil
+	}
+	line, rest, ok := bytes.Cut(ext[1:], []byte("\n"))
+	if !ok {
+		return nil
+	}
+	count, _, _ := bytes.Cut(line, []byte(" "))
+	if n, err := strconv.Atoi(string(count)); err != nil || n < 0 || len(rest) < len(Hash{}) {
+		return nil
+	}
+	var h Hash
+	copy(h[:], rest)
+	return &h
+}
+
+// Dirty reports whether any tracked files differ from the commit head,
+// either in the index or the working tree. head is nil when the branch
+// has no commits yet. ErrConverted is returned if nothing is staged but
+// the files in the working tree are converted.
+func (r *Repository) Dirty(head *Commit) (bool, error) {
+	idx, err := readIndex(filepath.Join(r.dir, "index"))
+	if errors.Is(err, os.ErrNotExist) {
+		return head != nil, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	staged, err := r.staged(idx, head)
+	if staged || err != nil {
+		return staged, err
+	}
+	if r.config.autocrlf || r.config.filters {
+		return false, ErrConverted
+	}
+	for _, e := range idx.entries {
+		if e.skipWorktree || e.mode&modeType == modeGitlink {
+			continue
+		}
+		modified, err := r.modified(e, idx.modTime)
+		if modified || err != nil {
+			return modified, err
+		}
+	}
+	return false, nil
+}
+
+// staged reports whether the index differs from the tree of head.
+func (r *Repository) staged(idx index, head *Commit) (bool, error) {
+	if head == nil {
+		return len(idx.entries) > 0, nil
+	}
+	if idx.tree != nil {
+		return *idx.tree != head.Tree, nil
+	}
+
+	tree := map[string]indexEntry{}
+	if err := r.readTree(head.Tree, "", tree); err != nil {
+		return false, err
+	}
+	if len(tree) != len(idx.entries) {
+		return true, nil
+	}
+	for _, e := range idx.entries {
+		t, ok := tree[e.name]
+		if !ok || e.stage != 0 || e.intentToAdd || t.hash != e.hash || t.mode != normalizeMode(e.mode) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// readTree adds the files in the tree h and its subtrees to entries.
+func (r *Repository) readTree(h Hash, prefix string, entries map[string]indexEntry) error {
+	typ, data, err := r.object(h)
+	if err != nil {
+		return err
+	}
+	if typ != objectTree {
+		return fmt.Errorf("object %s is a %s, not a tree", h, typ)
+	}
+	// Each entry is "mode name\x00" followed by the hash.
+	for len(data) > 0 {
+		header, rest, ok := bytes.Cut(data, []byte{0})
+		if !ok || len(rest) < len(Hash{}) {
+			return fmt.Errorf("invalid tree %s", h)
+		}
+		rawMode, name, _ := bytes.Cut(header, []byte(" "))
+		mode, err := strconv.ParseUint(string(rawMode), 8, 32)
+		if err != nil {
+			return fmt.Errorf("invalid tree %s: %w", h, err)
+		}
+		e := indexEntry{name: prefix + string(name), mode: uint32(mode)}
+		copy(e.hash[:], rest)
+		data = rest[len(Hash{}):]
+
+		if e.mode&modeType == modeDir {
+			if err := r.readTree(e.hash, e.name+"/", entries); err != nil {
+				return err
+			}
+			continue
+		}
+		entries[e.name] = e
+	}
+	return nil
+}
+
+// normalizeMode returns the mode an index entry has in a tree, where
+// files are either executable or not.
+func normalizeMode(mode uint32) uint32 {
+	if mode&modeType == modeFile {
+		if mode&0o111 != 0 {
+			return modeFile | 0o755
+		}
+		return modeFile | 0o644
+	}
+	return mode & modeType
+}
+
+// modified reports whether the file of e in the working tree differs
+// from the index. Files whose size and modification time are unchanged
+// are assumed to be unmodified, unless they might have been modified
+// after the index was written.
+func (r *Repository) modified(e indexEntry, indexModTime time.Time) (bool, error) {
+	if e.stage != 0 || e.intentToAdd {
+		return true, nil
+	}
+	filename := filepath.Join(r.workTree, filepath.FromSlash(e.name))
+	fi, err := os.Lstat(filename)
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch e.mode & modeType {
+	case modeSymlink:
+		if fi.Mode()&fs.ModeSymlink == 0 {
+			return true, nil
+		}
+		target, err := os.Readlink(filename)
+		if err != nil {
+			return false, err
+		}
+		h, err := blobHash(bytes.NewReader([]byte(target)), int64(len(target)))
+		return h != e.hash, err
+	case modeFile:
+		if !fi.Mode().IsRegular() {
+			return true, nil
+		}
+	default:
+		return false, nil
+	}
+
+	// Windows doesn't have an executable bit, and core.fileMode turns
+	// off comparing it.
+	if runtime.GOOS != "windows" && r.config.fileMode && (e.mode&0o111 != 0) != (fi.Mode()&0o111 != 0) {
+		return true, nil
+	}
+	if uint32(fi.Size()) != e.size {
+		return true, nil
+	}
+	mtime := fi.ModTime()
+	if uint32(mtime.Unix()) == e.mtimeSec && uint32(mtime.Nanosecond()) == e.mtimeNsec && mtime.Before(indexModTime) {
+		return false, nil
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	h, err := blobHash(f, fi.Size())
+	return h != e.hash, err
+}
+
+// blobHash returns the hash of a blob with the size bytes read from r.
+func blobHash(r io.Reader, size int64) (Hash, error) {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", size)
+	var sum Hash
+	if _, err := io.CopyN(h, r, size); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
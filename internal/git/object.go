@@ -0,0 +1,373 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type objectType int8
+
+const (
+	objectCommit   objectType = 1
+	objectTree     objectType = 2
+	objectBlob     objectType = 3
+	objectTag      objectType = 4
+	objectOfsDelta objectType = 6
+	objectRefDelta objectType = 7
+)
+
+func (t objectType) String() string {
+	switch t {
+	case objectCommit:
+		return "commit"
+	case objectTree:
+		return "tree"
+	case objectBlob:
+		return "blob"
+	case objectTag:
+		return "tag"
+	default:
+		return "unknown"
+	}
+}
+
+func objectTypeFrom(s string) objectType {
+	switch s {
+	case "commit":
+		return objectCommit
+	case "tree":
+		return objectTree
+	case "blob":
+		return objectBlob
+	case "tag":
+		return objectTag
+	default:
+		return 0
+	}
+}
+
+// maxDeltaDepth limits how long chains of deltas are followed, to not
+// loop forever on corrupt packs.
+const maxDeltaDepth = 10000
+
+// object returns the type and contents of the object h, which is either
+// a loose object or in a pack.
+func (r *Repository) object(h Hash) (objectType, []byte, error) {
+	return r.objectAt(h, 0)
+}
+
+func (r *Repository) objectAt(h Hash, depth int) (objectType, []byte, error) {
+	if depth > maxDeltaDepth {
+		return 0, nil, fmt.Errorf("delta chain of object %s is too long", h)
+	}
+	for _, dir := range r.objects {
+		typ, data, err := readLoose(filepath.Join(dir, h.String()[:2], h.String()[2:]))
+		if err == nil {
+			return typ, data, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return 0, nil, fmt.Errorf("failed to read object %s: %w", h, err)
+		}
+	}
+
+	if err := r.openPacks(); err != nil {
+		return 0, nil, err
+	}
+	for _, p := range r.packs {
+		if offset, ok := p.offset(h); ok {
+			typ, data, err := r.packed(p, offset, depth)
+			if err != nil {
+				return 0, nil, fmt.Errorf("failed to read object %s: %w", h, err)
+			}
+			return typ, data, nil
+		}
+	}
+	return 0, nil, fmt.Errorf("object %s not found", h)
+}
+
+// readLoose reads a zlib compressed object file, which starts with a
+// "type size\x00" header.
+func readLoose(filename string) (objectType, []byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+
+	zr, err := zlib.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer zr.Close()
+	br := bufio.NewReader(zr)
+	header, err := br.ReadString(0)
+	if err != nil {
+		return 0, nil, err
+	}
+	rawType, rawSize, _ := strings.Cut(strings.TrimSuffix(header, "\x00"), " ")
+	typ := objectTypeFrom(rawType)
+	size, err := strconv.ParseUint(rawSize, 10, 64)
+	if typ == 0 || err != nil {
+		return 0, nil, fmt.Errorf("invalid object header %q", header)
+	}
+	data := make([]byte, size)
+	_, err = io.ReadFull(br, data)
+	return typ, data, err
+}
+
+// pack is a pack file and its version 2 index.
+type pack struct {
+	idx  []byte
+	n    int
+	file *os.File
+}
+
+const (
+	idxHeaderSize = 8
+	idxFanoutSize = 256 * 4
+)
+
+func (r *Repository) openPacks() error {
+	if r.packsRead {
+		return nil
+	}
+	r.packsRead = true
+	for _, dir := range r.objects {
+		idxs, err := filepath.Glob(filepath.Join(dir, "pack", "*.idx"))
+		if err != nil {
+			return err
+		}
+		for _, idx := range idxs {
+			p, err := openPack(idx)
+			if err != nil {
+				return err
+			}
+			r.packs = append(r.packs, p)
+		}
+	}
+	return nil
+}
+
+func openPack(idxPath string) (*pack, error) {
+	idx, err := os.ReadFile(idxPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(idx) < idxHeaderSize+idxFanoutSize || !bytes.Equal(idx[:4], []byte("\xfftOc")) || binary.BigEndian.Uint32(idx[4:]) != 2 {
+		return nil, fmt.Errorf("unsupported pack index %s", idxPath)
+	}
+	n := int(binary.BigEndian.Uint32(idx[idxHeaderSize+idxFanoutSize-4:]))
+	// The hashes, CRCs and offsets of the objects.
+	if len(idx) < idxHeaderSize+idxFanoutSize+n*(len(Hash{})+4+4) {
+		return nil, fmt.Errorf("truncated pack index %s", idxPath)
+	}
+	file, err := os.Open(strings.TrimSuffix(idxPath, ".idx") + ".pack")
+	if err != nil {
+		return nil, err
+	}
+	return &pack{idx: idx, n: n, file: file}, nil
+}
+
+func (p *pack) Close() error {
+	return p.file.Close()
+}
+
+// offset returns where the object h is in the pack file.
+func (p *pack) offset(h Hash) (int64, bool) {
+	fanout := p.idx[idxHeaderSize:]
+	lo := 0
+	if h[0] > 0 {
+		lo = int(binary.BigEndian.Uint32(fanout[(int(h[0])-1)*4:]))
+	}
+	hi := int(binary.BigEndian.Uint32(fanout[int(h[0])*4:]))
+	if lo > hi || hi > p.n {
+		return 0, false
+	}
+
+	hashes := p.idx[idxHeaderSize+idxFanoutSize:]
+	name := func(i int) []byte {
+		return hashes[i*len(h) : (i+1)*len(h)]
+	}
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(name(lo+i), h[:]) >= 0
+	})
+	if i == hi || !bytes.Equal(name(i), h[:]) {
+		return 0, false
+	}
+
+	// The offsets follow the hashes and CRCs. Offsets with the most
+	// significant bit set are indexes into a table of 8 byte offsets.
+	offsets := hashes[p.n*(len(h)+4):]
+	offset := binary.BigEndian.Uint32(offsets[i*4:])
+	if offset&0x80000000 == 0 {
+		return int64(offset), true
+	}
+	large := offsets[p.n*4:]
+	j := int(offset&0x7fffffff) * 8
+	if j+8 > len(large) {
+		return 0, false
+	}
+	return int64(binary.BigEndian.Uint64(large[j:])), true
+}
+
+// packed reads the object at offset in p, applying deltas to their base
+// objects.
+func (r *Repository) packed(p *pack, offset int64, depth int) (objectType, []byte, error) {
+	if depth > maxDeltaDepth {
+		return 0, nil, fmt.Errorf("delta chain at offset %d is too long", offset)
+	}
+	br := bufio.NewReader(io.NewSectionReader(p.file, offset, math.MaxInt64-offset))
+
+	// The type and size are encoded in the first byte and a varint.
+	c, err := br.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	typ := objectType(c >> 4 & 7)
+	size := uint64(c & 0x0f)
+	for shift := 4; c&0x80 != 0; shift += 7 {
+		if c, err = br.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+		size |= uint64(c&0x7f) << shift
+	}
+
+	var baseType objectType
+	var base []byte
+	switch typ {
+	case objectCommit, objectTree, objectBlob, objectTag:
+		data, err := inflate(br, size)
+		return typ, data, err
+	case objectOfsDelta:
+		c, err := br.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		rel := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = br.ReadByte(); err != nil {
+				return 0, nil, err
+			}
+			rel = (rel+1)<<7 | int64(c&0x7f)
+		}
+		if rel <= 0 || rel > offset {
+			return 0, nil, fmt.Errorf("invalid delta base offset at %d", offset)
+		}
+		baseType, base, err = r.packed(p, offset-rel, depth+1)
+		if err != nil {
+			return 0, nil, err
+		}
+	case objectRefDelta:
+		var h Hash
+		if _, err := io.ReadFull(br, h[:]); err != nil {
+			return 0, nil, err
+		}
+		baseType, base, err = r.objectAt(h, depth+1)
+		if err != nil {
+			return 0, nil, err
+		}
+	default:
+		return 0, nil, fmt.Errorf("unknown object type %d at offset %d", typ, offset)
+	}
+
+	delta, err := inflate(br, size)
+	if err != nil {
+		return 0, nil, err
+	}
+	data, err := applyDelta(base, delta)
+	return baseType, data, err
+}
+
+func inflate(r io.Reader, size uint64) ([]byte, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	data := make([]byte, size)
+	_, err = io.ReadFull(zr, data)
+	return data, err
+}
+
+var errInvalidDelta = errors.New("invalid delta")
+
+// applyDelta creates an object from the instructions in delta, which copy
+// parts of base or insert new data.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	baseSize, delta, ok := deltaSize(delta)
+	if !ok || baseSize != uint64(len(base)) {
+		return nil, errInvalidDelta
+	}
+	size, delta, ok := deltaSize(delta)
+	if !ok {
+		return nil, errInvalidDelta
+	}
+
+	data := make([]byte, 0, size)
+	for len(delta) > 0 {
+		cmd := delta[0]
+		delta = delta[1:]
+		switch {
+		case cmd&0x80 != 0:
+			// The bits of cmd tell which bytes of the offset and size
+			// follow.
+			var offset, n uint64
+			for i := 0; i < 7; i++ {
+				if cmd&(1<<i) == 0 {
+					continue
+				}
+				if len(delta) == 0 {
+					return nil, errInvalidDelta
+				}
+				if i < 4 {
+					offset |= uint64(delta[0]) << (8 * i)
+				} else {
+					n |= uint64(delta[0]) << (8 * (i - 4))
+				}
+				delta = delta[1:]
+			}
+			if n == 0 {
+				n = 0x10000
+			}
+			if offset+n > uint64(len(base)) {
+				return nil, errInvalidDelta
+			}
+			data = append(data, base[offset:offset+n]...)
+		case cmd != 0:
+			if int(cmd) > len(delta) {
+				return nil, errInvalidDelta
+			}
+			data = append(data, delta[:cmd]...)
+			delta = delta[cmd:]
+		default:
+			return nil, errInvalidDelta
+		}
+	}
+	if uint64(len(data)) != size {
+		return nil, errInvalidDelta
+	}
+	return data, nil
+}
+
+// deltaSize reads a little-endian varint from the start of a delta.
+func deltaSize(delta []byte) (uint64, []byte, bool) {
+	var size uint64
+	for i, shift := 0, 0; i < len(delta) && shift < 64; i, shift = i+1, shift+7 {
+		size |= uint64(delta[i]&0x7f) << shift
+		if delta[i]&0x80 == 0 {
+			return size, delta[i+1:], true
+		}
+	}
+	return 0, nil, false
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"strconv"
+	"time"
 )
 
 type LokiExploreLink struct {
@@ -11,6 +13,10 @@ type LokiExploreLink struct {
 	DataSource    string
 	DataSourceUID string
 	TraceID       string
+	// From and To limit the time range of the query, it defaults to the
+	// last hour.
+	From time.Time
+	To   time.Time
 }
 
 func (x LokiExploreLink) URL() (*url.URL, error) {
@@ -28,10 +34,7 @@ func (x LokiExploreLink) URL() (*url.URL, error) {
 				Expr:       fmt.Sprintf("{source=\"go-test-runner\"} | logfmt | traceID=\"%s\" | line_format \"{{ .msg }}\"", x.TraceID),
 			},
 		},
-		Range: timeRange{
-			From: "now-1h",
-			To:   "now",
-		},
+		Range: x.timeRange(),
 	})
 	if err != nil {
 		return nil, err
@@ -47,6 +50,21 @@ func (x LokiExploreLink) URL() (*url.URL, error) {
 	return parsedURL, nil
 }
 
+func (x LokiExploreLink) timeRange() timeRange {
+	if x.From.IsZero() || x.To.IsZero() {
+		return timeRange{
+			From: "now-1h",
+			To:   "now",
+		}
+	}
+	// Grafana's time range is in milliseconds, round outwards to not
+	// miss the first and last lines.
+	return timeRange{
+		From: strconv.FormatInt(x.From.UnixMilli(), 10),
+		To:   strconv.FormatInt(x.To.Add(time.Millisecond).UnixMilli(), 10),
+	}
+}
+
 func (x LokiExploreLink) String() string {
 	parsedURL, err := x.URL()
 	if err != nil {
@@ -2,7 +2,9 @@ package loki
 
 import (
 	"bytes"
+	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/go-kit/log"
@@ -17,9 +19,25 @@ import (
 	"github.com/prometheus/common/model"
 )
 
+// reservedFields can't be overridden by test attributes, neither can the
+// fields of the run.
+var reservedFields = map[string]struct{}{
+	"msg":        {},
+	"package":    {},
+	"test":       {},
+	"state":      {},
+	"paused":     {},
+	"artifacts":  {},
+	"shard":      {},
+	"runID":      {},
+	"runAttempt": {},
+	"traceID":    {},
+}
+
 type EventSender struct {
-	client lokihttp.Client
-	r      *tests.Run
+	client  lokihttp.Client
+	r       *tests.Run
+	metrics *prometheus.Registry
 }
 
 func New(r *tests.Run, conf cfg.LokiOptions) (*EventSender, error) {
@@ -29,7 +47,8 @@ func New(r *tests.Run, conf cfg.LokiOptions) (*EventSender, error) {
 		return nil, err
 	}
 
-	loki, err := lokihttp.New(prometheus.NewRegistry(), lokihttp.Config{
+	reg := prometheus.NewRegistry()
+	loki, err := lokihttp.New(reg, lokihttp.Config{
 		URL:       lokiURL,
 		BatchWait: conf.BatchWait,
 		BatchSize: conf.BatchSize,
@@ -45,19 +64,52 @@ func New(r *tests.Run, conf cfg.LokiOptions) (*EventSender, error) {
 		return nil, err
 	}
 
-	return &EventSender{client: loki, r: r}, nil
+	return &EventSender{client: loki, r: r, metrics: reg}, nil
 }
 
 func (e EventSender) Handle(event tests.Event) error {
 	channel := e.client.Chan()
-	printer, ok := event.Payload.(tests.Print)
-	if !ok {
+
+	var msg string
+	var extra []any
+	switch ev := event.Payload.(type) {
+	case tests.Print:
+		msg = ev.Line
+	case tests.RawOutput:
+		msg = ev.Line
+	case tests.BuildOutput:
+		msg = ev.Line
+		extra = []any{"importPath", ev.ImportPath}
+	case tests.StateChange:
+		switch {
+		case ev.NewState == tests.StateBuildFailed:
+			msg = "build failed"
+			extra = []any{"state", ev.NewState, "failedBuild", ev.FailedBuild}
+		case ev.Usage != nil || len(ev.Profiles) > 0:
+			msg = "package finished"
+			extra = []any{"state", ev.NewState, "elapsed", ev.Elapsed}
+			if ev.Usage != nil {
+				extra = append(extra, usageFields(*ev.Usage)...)
+			}
+			if len(ev.Profiles) > 0 {
+				extra = append(extra, "profiles", strings.Join(ev.Profiles, ","))
+			}
+		default:
+			return nil
+		}
+	case tests.BenchmarkResult:
+		msg = "benchmark result"
+		extra = benchmarkFields(ev)
+	default:
 		return nil
 	}
 
-	kvs := []any{
-		"msg", printer.Line,
-		"package", event.Package,
+	kvs := []any{"msg", msg}
+	if event.Package != "" {
+		kvs = append(kvs, "package", event.Package)
+	}
+	if event.Shard != "" {
+		kvs = append(kvs, "shard", event.Shard)
 	}
 
 	for key, value := range e.r.Fields {
@@ -65,7 +117,7 @@ func (e EventSender) Handle(event tests.Event) error {
 	}
 
 	if event.Test != "" {
-		test, err := e.r.Get(event.Package, event.Test)
+		test, err := e.r.Get(event.Shard, event.Package, event.Test)
 		if err != nil {
 			return err
 		}
@@ -74,23 +126,90 @@ func (e EventSender) Handle(event tests.Event) error {
 			"test", event.Test,
 			"state", test.State,
 		)
+		if test.Paused > 0 {
+			kvs = append(kvs, "paused", test.Paused)
+		}
+		if test.Artifacts != "" {
+			kvs = append(kvs, "artifacts", test.Artifacts)
+		}
+		for key, value := range test.Attributes {
+			_, reserved := reservedFields[key]
+			_, runField := e.r.Fields[key]
+			if !reserved && !runField {
+				kvs = append(kvs, key, value)
+			}
+		}
 	}
+	kvs = append(kvs, extra...)
 
 	buf := &bytes.Buffer{}
 	logger := log.NewLogfmtLogger(buf)
 	logger.Log(kvs...)
 
+	ts := event.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
 	channel <- lokihttp.Entry{
 		Labels: model.LabelSet{"source": "go-test-runner"},
 		Entry: logproto.Entry{
-			//Timestamp: event.Timestamp,
-			Timestamp: time.Now(),
+			Timestamp: ts,
 			Line:      buf.String(),
 		},
 	}
 	return nil
 }
 
+func benchmarkFields(b tests.BenchmarkResult) []any {
+	kvs := []any{
+		"benchmark", b.Name,
+		"iterations", b.Iterations,
+	}
+	if b.Procs != 0 {
+		kvs = append(kvs, "procs", b.Procs)
+	}
+	for _, unit := range b.Units() {
+		kvs = append(kvs, tests.MetricKey(unit), b.Metrics[unit])
+	}
+	return kvs
+}
+
+func usageFields(u tests.ResourceUsage) []any {
+	return []any{
+		"userCPU", u.UserCPU,
+		"systemCPU", u.SystemCPU,
+		"wall", u.Wall,
+		"maxRSS", u.MaxRSS,
+		"voluntaryContextSwitches", u.VoluntaryContextSwitches,
+		"involuntaryContextSwitches", u.InvoluntaryContextSwitches,
+	}
+}
+
 func (e *EventSender) Stop() {
 	e.client.Stop()
 }
+
+// Err returns an error if any log entries were dropped after exhausting
+// all retries.
+func (e *EventSender) Err() error {
+	families, err := e.metrics.Gather()
+	if err != nil {
+		return err
+	}
+
+	dropped := 0.0
+	for _, family := range families {
+		if family.GetName() != "promtail_dropped_entries_total" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			dropped += m.GetCounter().GetValue()
+		}
+	}
+
+	if dropped > 0 {
+		return fmt.Errorf("failed to send %d log entries to Loki", int(dropped))
+	}
+	return nil
+}
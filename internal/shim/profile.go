@@ -0,0 +1,166 @@
+package shim
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/go-test-runner/internal/tests"
+)
+
+// ProfileDir returns the directory in which the profiles of the run with
+// traceID are saved.
+func ProfileDir(outputDir, traceID string) string {
+	return filepath.Join(outputDir, traceID, "profiles")
+}
+
+// profile is a kind of profile written by test binaries.
+type profile struct {
+	name string
+	flag string
+}
+
+var profiles = []profile{
+	{name: "cpu", flag: "-test.cpuprofile"},
+	{name: "mem", flag: "-test.memprofile"},
+}
+
+func (p profile) path(dir, pkg string) string {
+	return filepath.Join(dir, filepath.FromSlash(pkg), p.name+".pprof")
+}
+
+// withProfiles adds the flags for writing profiles for pkg in dir to the
+// arguments of a test binary, unless they're already set.
+func withProfiles(args []string, dir, pkg string) ([]string, error) {
+	if err := os.MkdirAll(filepath.Join(dir, filepath.FromSlash(pkg)), 0o755); err != nil {
+		return args, err
+	}
+	flags := []string{}
+	for _, p := range profiles {
+		if !hasTestFlag(args, p.flag) {
+			flags = append(flags, p.flag+"="+p.path(dir, pkg))
+		}
+	}
+	// Test binaries stop parsing flags at the first argument that isn't
+	// a flag, so the flags go first.
+	withFlags := append([]string{args[0]}, flags...)
+	return append(withFlags, args[1:]...), nil
+}
+
+func hasTestFlag(args []string, flag string) bool {
+	for _, arg := range args[1:] {
+		if arg == flag || strings.HasPrefix(arg, flag+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// keepProfiles removes the profiles for pkg in dir if the test binary
+// ran for less than minDuration, and returns the profiles that are kept.
+func keepProfiles(dir, pkg string, wall, minDuration time.Duration) []string {
+	kept := []string{}
+	for _, p := range profiles {
+		path := p.path(dir, pkg)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if wall < minDuration {
+			os.Remove(path)
+			continue
+		}
+		kept = append(kept, path)
+	}
+	return kept
+}
+
+// pushProfiles sends the profiles to a Pyroscope server's ingest API.
+func pushProfiles(pyroscopeURL string, paths []string, pkg, traceID string, from, until time.Time) error {
+	var errs []error
+	for _, path := range paths {
+		errs = append(errs, pushProfile(pyroscopeURL, path, pkg, traceID, from, until))
+	}
+	return errors.Join(errs...)
+}
+
+func pushProfile(pyroscopeURL, path, pkg, traceID string, from, until time.Time) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	part, err := w.CreateFormFile("profile", filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	u, err := url.Parse(pyroscopeURL)
+	if err != nil {
+		return err
+	}
+	u = u.JoinPath("ingest")
+	u.RawQuery = url.Values{
+		"name":       {fmt.Sprintf("go-test-runner{package=%s,traceID=%s}", pkg, traceID)},
+		"from":       {strconv.FormatInt(from.Unix(), 10)},
+		"until":      {strconv.FormatInt(until.Unix(), 10)},
+		"format":     {"pprof"},
+		"spyName":    {"gospy"},
+		"sampleRate": {"100"},
+	}.Encode()
+
+	resp, err := http.Post(u.String(), w.FormDataContentType(), body)
+	if err != nil {
+		return fmt.Errorf("failed to push profile %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to push profile %s: %s", path, resp.Status)
+	}
+	return nil
+}
+
+// ProfileReader adds the profiles saved by the shim to the events for
+// packages finishing.
+type ProfileReader struct {
+	dir string
+}
+
+// NewProfileReader returns a ProfileReader for the profiles saved in dir.
+func NewProfileReader(dir string) *ProfileReader {
+	return &ProfileReader{dir: dir}
+}
+
+// Rewrite sets tests.StateChange.Profiles when a package is done and its
+// test binary's profiles were kept.
+func (r *ProfileReader) Rewrite(e tests.Event) tests.Event {
+	ev, ok := e.Payload.(tests.StateChange)
+	if !ok || e.Test != "" || !ev.NewState.Done() {
+		return e
+	}
+	for _, p := range profiles {
+		path := p.path(r.dir, e.Package)
+		if _, err := os.Stat(path); err == nil {
+			ev.Profiles = append(ev.Profiles, path)
+		}
+	}
+	e.Payload = ev
+	return e
+}
@@ -0,0 +1,42 @@
+package shim
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ResourceEnv is the environment variable with the resource attributes
+// detected by the run, which the shim adds to the resource of its spans.
+const ResourceEnv = "GT_EXEC_RESOURCE"
+
+// ResourceString returns attrs on the format of ResourceEnv, which is the
+// format of OTEL_RESOURCE_ATTRIBUTES.
+func ResourceString(attrs []attribute.KeyValue) string {
+	pairs := make([]string, 0, len(attrs))
+	for _, attr := range attrs {
+		pairs = append(pairs, url.PathEscape(string(attr.Key))+"="+url.PathEscape(attr.Value.Emit()))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// resource returns the attributes in ResourceEnv.
+func resource() ([]attribute.KeyValue, error) {
+	var attrs []attribute.KeyValue
+	for _, pair := range strings.Split(os.Getenv(ResourceEnv), ",") {
+		if pair == "" {
+			continue
+		}
+		rawKey, rawValue, _ := strings.Cut(pair, "=")
+		key, keyErr := url.PathUnescape(rawKey)
+		value, valueErr := url.PathUnescape(rawValue)
+		if keyErr != nil || valueErr != nil {
+			return nil, fmt.Errorf("invalid resource attribute %q in %s", pair, ResourceEnv)
+		}
+		attrs = append(attrs, attribute.String(key, value))
+	}
+	return attrs, nil
+}
@@ -0,0 +1,192 @@
+package shim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/grafana/go-test-runner/internal/cfg"
+	"github.com/grafana/go-test-runner/internal/detect"
+	"github.com/grafana/go-test-runner/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Options configures the shim.
+type Options struct {
+	Tracing cfg.TracingOptions
+	Run     cfg.RunOptions
+	Profile cfg.ProfileOptions
+	// OutputDir is the directory files written for the run are saved in.
+	OutputDir string
+}
+
+// Run runs the test binary args[0] with the arguments args[1:], which is
+// how `go test -exec` invokes go-test-runner. The binary runs in a
+// test/binary span which is a child of its package's span, and the span's
+// trace context is passed to it using TRACEPARENT and TRACESTATE. The
+// span's resource gets the attributes in ResourceEnv. The test binary's
+// exit code is returned.
+//
+// The resource usage of the test binary is saved in the directory set in
+// UsageDirEnv, if any. When profiling is enabled, the binary's CPU and
+// heap profiles are saved in the run's output directory.
+//
+// If the span cannot be created or the resource usage or profiles cannot
+// be saved, the test binary is run anyway and the returned error is set.
+func Run(args []string, opts Options) (int, error) {
+	if len(args) == 0 {
+		return -1, fmt.Errorf("no test binary to run")
+	}
+	pkg, err := packagePath()
+	errs := []error{err}
+
+	profileDir := ""
+	if opts.Profile.Enabled && pkg != "" && opts.Run.TraceID.IsValid() {
+		profileDir = ProfileDir(opts.OutputDir, opts.Run.TraceID.String())
+		args, err = withProfiles(args, profileDir, pkg)
+		errs = append(errs, err)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+
+	var span trace.Span
+	var stop func() error
+	if pkg != "" {
+		span, stop, err = start(cmd, pkg, opts.Tracing, opts.Run)
+		errs = append(errs, err)
+	}
+
+	started := time.Now()
+	err = cmd.Run()
+	ended := time.Now()
+	wall := ended.Sub(started)
+	exitCode := 0
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		exitCode = exitErr.ExitCode()
+	case err != nil:
+		return -1, err
+	}
+
+	usage := resourceUsage(cmd.ProcessState, wall)
+	if dir := os.Getenv(UsageDirEnv); dir != "" && pkg != "" {
+		errs = append(errs, saveUsage(dir, pkg, usage))
+	}
+	var kept []string
+	if profileDir != "" {
+		kept = keepProfiles(profileDir, pkg, wall, opts.Profile.MinDuration)
+		if opts.Profile.PyroscopeURL != "" {
+			errs = append(errs, pushProfiles(opts.Profile.PyroscopeURL, kept, pkg, opts.Run.TraceID.String(), started, ended))
+		}
+	}
+	if span != nil {
+		span.SetAttributes(attribute.Int("exitCode", exitCode))
+		span.SetAttributes(usage.Attributes()...)
+		if len(kept) > 0 {
+			span.SetAttributes(attribute.StringSlice("profiles", kept))
+		}
+		if exitCode != 0 {
+			span.SetStatus(codes.Error, "test binary exited with "+strconv.Itoa(exitCode))
+		}
+		span.End()
+		errs = append(errs, stop())
+	}
+	return exitCode, errors.Join(errs...)
+}
+
+// start starts the span for the test binary of pkg run by cmd and adds
+// its trace context to cmd's environment. The function returned flushes
+// the span.
+func start(cmd *exec.Cmd, pkg string, tracingOptions cfg.TracingOptions, runOptions cfg.RunOptions) (trace.Span, func() error, error) {
+	if !runOptions.TraceID.IsValid() {
+		return nil, nil, fmt.Errorf("the trace ID of the run is unknown")
+	}
+
+	attrs, err := resource()
+	if err != nil {
+		return nil, nil, err
+	}
+	ids := tracing.NewIDGenerator(runOptions.TraceID, "")
+	tp, err := tracing.Provider(tracingOptions, ids, attrs...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    runOptions.TraceID,
+		SpanID:     tracing.PackageSpanID(runOptions.TraceID, runOptions.Shard, pkg),
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	ctx, span := tp.Tracer("go-test-runner").Start(trace.ContextWithRemoteSpanContext(context.Background(), parent), "test/binary")
+	span.SetAttributes(
+		attribute.String("packageName", pkg),
+		attribute.String("binary", filepath.Base(cmd.Path)),
+	)
+
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	cmd.Env = append(cmd.Env, "TRACEPARENT="+carrier.Get("traceparent"))
+	if state := carrier.Get("tracestate"); state != "" {
+		cmd.Env = append(cmd.Env, "TRACESTATE="+state)
+	}
+	// Let OpenTelemetry SDKs in the tests send their spans to the same
+	// place, unless they're configured otherwise.
+	env := tracing.Env(tracingOptions)
+	env["OTEL_PROPAGATORS"] = "tracecontext,baggage"
+	env["OTEL_SERVICE_NAME"] = pkg
+	for key, value := range env {
+		if _, ok := os.LookupEnv(key); !ok {
+			cmd.Env = append(cmd.Env, key+"="+value)
+		}
+	}
+
+	return span, func() error {
+		return tp.Shutdown(context.Background())
+	}, nil
+}
+
+// packagePath returns the import path of the package being tested, the
+// go command runs test binaries in the package's directory.
+func packagePath() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return importPath(dir)
+}
+
+// importPath returns the import path of the package in dir, using the
+// module path from the closest go.mod file.
+func importPath(dir string) (string, error) {
+	for modDir := dir; ; modDir = filepath.Dir(modDir) {
+		module, err := detect.ModulePath(filepath.Join(modDir, "go.mod"))
+		if err == nil {
+			rel, err := filepath.Rel(modDir, dir)
+			if err != nil {
+				return "", err
+			}
+			return path.Join(module, filepath.ToSlash(rel)), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		if filepath.Dir(modDir) == modDir {
+			return "", fmt.Errorf("no go.mod found for %s", dir)
+		}
+	}
+}
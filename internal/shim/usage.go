@@ -0,0 +1,66 @@
+package shim
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/grafana/go-test-runner/internal/tests"
+)
+
+// UsageDirEnv is the environment variable with the directory in which
+// the shim saves the resource usage of the test binaries.
+const UsageDirEnv = "GT_EXEC_USAGE_DIR"
+
+func usageFile(dir, pkg string) string {
+	return filepath.Join(dir, url.QueryEscape(pkg)+".json")
+}
+
+func saveUsage(dir, pkg string, usage tests.ResourceUsage) error {
+	b, err := json.Marshal(usage)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(usageFile(dir, pkg), b, 0o644)
+}
+
+// UsageReader adds the resource usage saved by the shim to the events
+// for packages finishing.
+type UsageReader struct {
+	dir string
+}
+
+// NewUsageReader returns a UsageReader for the resource usage saved in
+// dir.
+func NewUsageReader(dir string) *UsageReader {
+	return &UsageReader{dir: dir}
+}
+
+// Rewrite sets tests.StateChange.Usage when a package is done and its
+// test binary's resource usage was saved.
+func (u *UsageReader) Rewrite(e tests.Event) tests.Event {
+	ev, ok := e.Payload.(tests.StateChange)
+	if !ok || e.Test != "" || !ev.NewState.Done() {
+		return e
+	}
+
+	filename := usageFile(u.dir, e.Package)
+	b, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return e
+	}
+	usage := tests.ResourceUsage{}
+	if err == nil {
+		err = json.Unmarshal(b, &usage)
+	}
+	if err != nil {
+		return e
+	}
+	os.Remove(filename)
+
+	ev.Usage = &usage
+	e.Payload = ev
+	return e
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package shim
+
+import (
+	"os"
+	"time"
+
+	"github.com/grafana/go-test-runner/internal/tests"
+)
+
+func resourceUsage(state *os.ProcessState, wall time.Duration) tests.ResourceUsage {
+	return tests.ResourceUsage{
+		UserCPU:   state.UserTime(),
+		SystemCPU: state.SystemTime(),
+		Wall:      wall,
+	}
+}
@@ -0,0 +1,30 @@
+//go:build unix
+
+package shim
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/grafana/go-test-runner/internal/tests"
+)
+
+func resourceUsage(state *os.ProcessState, wall time.Duration) tests.ResourceUsage {
+	usage := tests.ResourceUsage{
+		UserCPU:   state.UserTime(),
+		SystemCPU: state.SystemTime(),
+		Wall:      wall,
+	}
+	if ru, ok := state.SysUsage().(*syscall.Rusage); ok {
+		usage.MaxRSS = int64(ru.Maxrss)
+		// Everywhere but on Apple's systems, the max RSS is in kilobytes.
+		if runtime.GOOS != "darwin" && runtime.GOOS != "ios" {
+			usage.MaxRSS *= 1024
+		}
+		usage.VoluntaryContextSwitches = int64(ru.Nvcsw)
+		usage.InvoluntaryContextSwitches = int64(ru.Nivcsw)
+	}
+	return usage
+}
@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Units reported by the testing package for every benchmark, or when
+// running with -benchmem.
+const (
+	UnitNsPerOp     = "ns/op"
+	UnitBytesPerOp  = "B/op"
+	UnitAllocsPerOp = "allocs/op"
+	UnitMBPerSecond = "MB/s"
+)
+
+// BenchmarkResult is the result of a benchmark, parsed from a line such as
+//
+//	BenchmarkFoo-8  1000  1234 ns/op  56 B/op  2 allocs/op
+//
+// Metrics holds every reported value keyed by its unit, including
+// custom units reported using testing.B.ReportMetric.
+type BenchmarkResult struct {
+	Name       string             `json:"name"`
+	Procs      int                `json:"procs,omitempty"`
+	Iterations int64              `json:"iterations"`
+	Metrics    map[string]float64 `json:"metrics"`
+}
+
+func (BenchmarkResult) isEventPayload() {}
+
+// ParseBenchmark parses a benchmark result line. The second return
+// value is false if the line is not a benchmark result.
+func ParseBenchmark(line string) (BenchmarkResult, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || len(fields)%2 != 0 || !isBenchmarkName(fields[0]) {
+		return BenchmarkResult{}, false
+	}
+
+	iterations, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return BenchmarkResult{}, false
+	}
+
+	metrics := make(map[string]float64, (len(fields)-2)/2)
+	for i := 2; i < len(fields); i += 2 {
+		value, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return BenchmarkResult{}, false
+		}
+		metrics[fields[i+1]] = value
+	}
+
+	name, procs := fields[0], 0
+	if i := strings.LastIndex(name, "-"); i > 0 {
+		if n, err := strconv.Atoi(name[i+1:]); err == nil {
+			name, procs = name[:i], n
+		}
+	}
+
+	return BenchmarkResult{
+		Name:       name,
+		Procs:      procs,
+		Iterations: iterations,
+		Metrics:    metrics,
+	}, true
+}
+
+// isBenchmarkName mirrors the check used by test2json, a benchmark
+// name is "Benchmark" optionally followed by a non-lowercase rune.
+func isBenchmarkName(s string) bool {
+	rest, ok := strings.CutPrefix(s, "Benchmark")
+	if !ok {
+		return false
+	}
+	for _, r := range rest {
+		return !unicode.IsLower(r)
+	}
+	return true
+}
+
+// MetricKey turns a benchmark unit into a key suitable for span
+// attributes and logfmt fields, e.g. "ns/op" becomes "ns_per_op".
+func MetricKey(unit string) string {
+	unit = strings.ReplaceAll(unit, "/", "_per_")
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+		return '_'
+	}, unit)
+}
+
+// Attributes returns the benchmark result as span attributes.
+func (b BenchmarkResult) Attributes() []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("benchmark.name", b.Name),
+		attribute.Int64("benchmark.iterations", b.Iterations),
+	}
+	if b.Procs != 0 {
+		attrs = append(attrs, attribute.Int("benchmark.procs", b.Procs))
+	}
+	for _, unit := range b.Units() {
+		attrs = append(attrs, attribute.Float64("benchmark."+MetricKey(unit), b.Metrics[unit]))
+	}
+	return attrs
+}
+
+// Units returns the units of the benchmark's metrics in sorted order.
+func (b BenchmarkResult) Units() []string {
+	units := make([]string, 0, len(b.Metrics))
+	for unit := range b.Metrics {
+		units = append(units, unit)
+	}
+	sort.Strings(units)
+	return units
+}
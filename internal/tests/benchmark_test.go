@@ -0,0 +1,36 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBenchmark(t *testing.T) {
+	for line, want := range map[string]BenchmarkResult{
+		"BenchmarkA-8 \t 1000\t 123.4 ns/op\n": {Name: "BenchmarkA", Procs: 8, Iterations: 1000, Metrics: map[string]float64{"ns/op": 123.4}},
+		"BenchmarkA/sub-case-16 \t 50\t 2000 ns/op\t 64 B/op\t 2 allocs/op\t 12.5 MB/s\n": {
+			Name: "BenchmarkA/sub-case", Procs: 16, Iterations: 50,
+			Metrics: map[string]float64{"ns/op": 2000, "B/op": 64, "allocs/op": 2, "MB/s": 12.5},
+		},
+		"Benchmark \t 10\t 1 ns/op": {Name: "Benchmark", Iterations: 10, Metrics: map[string]float64{"ns/op": 1}},
+	} {
+		got, ok := ParseBenchmark(line)
+		require.True(t, ok, line)
+		require.Equal(t, want, got, line)
+	}
+
+	for _, line := range []string{
+		"",
+		"PASS\n",
+		"Benchmarking is fun 1 ns/op\n",
+		"Benchmarkfoo \t 10\t 1 ns/op\n",
+		"BenchmarkA-8 \t 1000\n",
+		"BenchmarkA-8 \t many\t 1 ns/op\n",
+		"BenchmarkA-8 \t 1000\t fast ns/op\n",
+		"BenchmarkA-8 \t 1000\t 1 ns/op\t 2\n",
+	} {
+		_, ok := ParseBenchmark(line)
+		require.False(t, ok, line)
+	}
+}
@@ -2,18 +2,26 @@ package tests
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
+	"strings"
 	"time"
 )
 
 type goTestLine struct {
-	Time    time.Time
-	Action  string
-	Package string
-	Elapsed float64
-	Output  string
-	Test    string
+	Time        time.Time
+	Action      string
+	Package     string
+	Elapsed     float64
+	Output      string
+	Test        string
+	ImportPath  string
+	FailedBuild string
+	Key         string
+	Value       string
+	Path        string
 }
 
 func (l goTestLine) Events() []Event {
@@ -28,33 +36,169 @@ func (l goTestLine) Events() []Event {
 		e.Payload = Print{Line: l.Output}
 	case "start":
 		e.Payload = StateChange{NewState: StateRunning}
+	case "run":
+		e.Payload = Started{}
+	case "pause":
+		e.Payload = Paused{}
+	case "cont":
+		e.Payload = Continued{}
+	case "bench":
+		e.Payload = Benchmarked{Elapsed: l.elapsed()}
 	case "skip":
-		e.Payload = StateChange{NewState: StateSkipped}
+		e.Payload = StateChange{NewState: StateSkipped, Elapsed: l.elapsed()}
 	case "pass":
-		e.Payload = StateChange{NewState: StatePassed}
+		e.Payload = StateChange{NewState: StatePassed, Elapsed: l.elapsed()}
 	case "fail":
-		e.Payload = StateChange{NewState: StateFailed}
+		e.Payload = StateChange{NewState: StateFailed, Elapsed: l.elapsed()}
+		if l.FailedBuild != "" {
+			e.Payload = StateChange{NewState: StateBuildFailed, Elapsed: l.elapsed(), FailedBuild: l.FailedBuild}
+		}
+	case "artifacts":
+		e.Payload = Artifacts{Dir: l.Path}
+	case "attr":
+		e.Payload = Attribute{Key: l.Key, Value: l.Value}
+	case "build-output":
+		e.Payload = BuildOutput{ImportPath: l.ImportPath, Line: l.Output}
+	case "build-fail":
+		e.Payload = BuildFailed{ImportPath: l.ImportPath}
 	}
 
 	return []Event{e}
 }
 
+func (l goTestLine) elapsed() time.Duration {
+	return time.Duration(l.Elapsed * float64(time.Second))
+}
+
+// ParseError is returned by GoJSON.ReadLine when a line could be read
+// but not decoded.
+type ParseError struct {
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("failed to parse line: %v", e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
 type GoJSON struct {
-	scanner *bufio.Scanner
+	// Shard is set on all events read.
+	Shard string
+	// Last is the most recent timestamp read from the input. It can be
+	// set to continue from the end of a previous input.
+	Last time.Time
+
+	reader *bufio.Reader
+	// err is the error from reading the last line, which is returned
+	// after the part of the line that was read.
+	err error
+	// partial holds the beginning of benchmark result lines per package,
+	// test2json emits the benchmark name before the benchmark has run.
+	partial map[string]string
 }
 
 func NewGoJSON(r io.Reader) *GoJSON {
-	return &GoJSON{scanner: bufio.NewScanner(r)}
+	return &GoJSON{reader: bufio.NewReader(r), partial: map[string]string{}}
 }
 
+// ReadLine reads and decodes the next line of input, regardless of its
+// length. Lines which aren't JSON objects, such as build errors, are
+// returned as RawOutput. It returns io.EOF once all input has been read,
+// a *ParseError if a JSON line couldn't be decoded, and any other error
+// if reading failed.
 func (j *GoJSON) ReadLine() ([]Event, error) {
-	if !j.scanner.Scan() {
-		return nil, io.EOF
+	raw, err := j.next()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+		e := rawOutput(raw, j.timestamp(time.Time{}))
+		e.Shard = j.Shard
+		return []Event{e}, nil
 	}
 	line := goTestLine{}
-	err := json.Unmarshal(j.scanner.Bytes(), &line)
+	err = json.Unmarshal(raw, &line)
 	if err != nil {
-		return nil, err
+		return nil, &ParseError{Err: err}
+	}
+	line.Time = j.timestamp(line.Time)
+	events := line.Events()
+	for i := range events {
+		events[i].Shard = j.Shard
+	}
+	if result, ok := j.benchmark(line); ok {
+		events = append(events, Event{
+			Package:   line.Package,
+			Test:      line.Test,
+			Shard:     j.Shard,
+			Timestamp: line.Time,
+			Payload:   result,
+		})
+	}
+	return events, nil
+}
+
+// timestamp fills in the time of lines without one, such as raw output,
+// build output and cached test results, using the most recent timestamp
+// of the input. This keeps replayed input in its original order.
+func (j *GoJSON) timestamp(t time.Time) time.Time {
+	if !t.IsZero() {
+		j.Last = t
+		return t
+	}
+	if j.Last.IsZero() {
+		return time.Now()
+	}
+	return j.Last
+}
+
+func rawOutput(raw []byte, ts time.Time) Event {
+	line := string(raw)
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+	return Event{
+		Timestamp: ts,
+		Payload:   RawOutput{Line: line},
+	}
+}
+
+// next returns the next non-empty line. A line ended by an error is
+// returned before the error.
+func (j *GoJSON) next() ([]byte, error) {
+	for j.err == nil {
+		raw, err := j.reader.ReadBytes('\n')
+		switch {
+		case err == io.EOF:
+			j.err = io.EOF
+		case err != nil:
+			j.err = fmt.Errorf("failed to read line: %w", err)
+		}
+		if len(bytes.TrimSpace(raw)) != 0 {
+			return raw, nil
+		}
+	}
+	return nil, j.err
+}
+
+func (j *GoJSON) benchmark(l goTestLine) (BenchmarkResult, bool) {
+	if l.Action != "output" {
+		return BenchmarkResult{}, false
+	}
+
+	output := l.Output
+	if partial, ok := j.partial[l.Package]; ok {
+		output = partial + output
+		delete(j.partial, l.Package)
+	}
+	if !strings.HasSuffix(output, "\n") {
+		if strings.HasPrefix(output, "Benchmark") {
+			j.partial[l.Package] = output
+		}
+		return BenchmarkResult{}, false
 	}
-	return line.Events(), nil
+	return ParseBenchmark(output)
 }
@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// readAll reads events until ReadLine returns an error other than a
+// *ParseError, which is returned.
+func readAll(t *testing.T, j *GoJSON) ([]Event, error) {
+	t.Helper()
+	var events []Event
+	for {
+		es, err := j.ReadLine()
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			continue
+		}
+		if err != nil {
+			return events, err
+		}
+		events = append(events, es...)
+	}
+}
+
+func TestGoJSONLongLine(t *testing.T) {
+	output := strings.Repeat("x", 100*1024)
+	events, err := readAll(t, NewGoJSON(strings.NewReader(
+		`{"Action":"output","Package":"example.com/a","Output":"`+output+`\n"}`+"\n",
+	)))
+	require.ErrorIs(t, err, io.EOF)
+	require.Len(t, events, 1)
+	require.Equal(t, Print{Line: output + "\n"}, events[0].Payload)
+}
+
+func TestGoJSONLastLineWithoutNewline(t *testing.T) {
+	events, err := readAll(t, NewGoJSON(strings.NewReader(
+		`{"Action":"start","Package":"example.com/a"}`+"\n"+
+			`{"Action":"pass","Package":"example.com/a"}`,
+	)))
+	require.ErrorIs(t, err, io.EOF)
+	require.Len(t, events, 2)
+	require.Equal(t, StateChange{NewState: StatePassed}, events[1].Payload)
+}
+
+// failingReader returns err once after reading all of r, and then
+// io.EOF.
+type failingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF && f.err != nil {
+		err, f.err = f.err, nil
+	}
+	return n, err
+}
+
+func TestGoJSONReadError(t *testing.T) {
+	errRead := errors.New("read failed")
+	input := `{"Action":"start","Package":"example.com/a"}` + "\n" + `{"Action":"pass",`
+	j := NewGoJSON(&failingReader{r: strings.NewReader(input), err: errRead})
+
+	events, err := j.ReadLine()
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+
+	// The partial line is returned before the error.
+	_, err = j.ReadLine()
+	var parseErr *ParseError
+	require.ErrorAs(t, err, &parseErr)
+
+	_, err = j.ReadLine()
+	require.ErrorIs(t, err, errRead)
+	require.NotErrorIs(t, err, io.EOF)
+	_, err = j.ReadLine()
+	require.ErrorIs(t, err, errRead)
+}
+
+func TestGoJSONBenchmark(t *testing.T) {
+	// test2json emits the benchmark's name before it has run, and the
+	// result in a later output line.
+	events, err := readAll(t, NewGoJSON(strings.NewReader(strings.Join([]string{
+		`{"Action":"output","Package":"example.com/a","Test":"BenchmarkA","Output":"BenchmarkA\n"}`,
+		`{"Action":"output","Package":"example.com/a","Output":"BenchmarkA-8 \t"}`,
+		`{"Action":"output","Package":"example.com/b","Output":"BenchmarkB-8 \t"}`,
+		`{"Action":"output","Package":"example.com/a","Output":"    1000\t       123.4 ns/op\n"}`,
+		`{"Action":"output","Package":"example.com/b","Output":"      20\t        50 ns/op\t       8 B/op\n"}`,
+		`{"Action":"output","Package":"example.com/a","Output":"PASS\n"}`,
+	}, "\n"))))
+	require.ErrorIs(t, err, io.EOF)
+
+	var results []Event
+	for _, e := range events {
+		if _, ok := e.Payload.(BenchmarkResult); ok {
+			results = append(results, e)
+		}
+	}
+	require.Len(t, results, 2)
+	require.Equal(t, "example.com/a", results[0].Package)
+	require.Equal(t, BenchmarkResult{Name: "BenchmarkA", Procs: 8, Iterations: 1000, Metrics: map[string]float64{"ns/op": 123.4}}, results[0].Payload)
+	require.Equal(t, "example.com/b", results[1].Package)
+	require.Equal(t, BenchmarkResult{Name: "BenchmarkB", Procs: 8, Iterations: 20, Metrics: map[string]float64{"ns/op": 50, "B/op": 8}}, results[1].Payload)
+}
@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"strings"
+	"text/template"
+
+	"github.com/grafana/go-test-runner/internal/cfg"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+// Attributes from the OpenTelemetry semantic conventions for tests, which
+// are newer than the semconv packages of the OpenTelemetry SDK in use.
+const (
+	testSuiteNameKey      = attribute.Key("test.suite.name")
+	testSuiteRunStatusKey = attribute.Key("test.suite.run.status")
+	testCaseNameKey       = attribute.Key("test.case.name")
+	testCaseResultKey     = attribute.Key("test.case.result.status")
+)
+
+// suiteAttributes returns the semantic convention attributes of the
+// span of the package pkg.
+func suiteAttributes(pkg string) []attribute.KeyValue {
+	return []attribute.KeyValue{testSuiteNameKey.String(pkg)}
+}
+
+// caseAttributes returns the semantic convention attributes of the span
+// of test in pkg. The code function is the top-level test, as subtests
+// are not functions of their own.
+func caseAttributes(pkg, test string) []attribute.KeyValue {
+	function, _, _ := strings.Cut(test, "/")
+	return []attribute.KeyValue{
+		testSuiteNameKey.String(pkg),
+		testCaseNameKey.String(pkg + "." + test),
+		semconv.CodeNamespaceKey.String(pkg),
+		semconv.CodeFunctionKey.String(function),
+	}
+}
+
+// suiteStatus returns the test.suite.run.status of a package in state.
+func suiteStatus(state State) (attribute.KeyValue, bool) {
+	switch state {
+	case StatePassed:
+		return testSuiteRunStatusKey.String("success"), true
+	case StateFailed, StateBuildFailed:
+		return testSuiteRunStatusKey.String("failure"), true
+	case StateSkipped:
+		return testSuiteRunStatusKey.String("skipped"), true
+	}
+	return attribute.KeyValue{}, false
+}
+
+// caseResult returns the test.case.result.status of a test in state.
+func caseResult(state State) (attribute.KeyValue, bool) {
+	switch state {
+	case StatePassed:
+		return testCaseResultKey.String("pass"), true
+	case StateFailed:
+		return testCaseResultKey.String("fail"), true
+	}
+	return attribute.KeyValue{}, false
+}
+
+// spanName returns the name from executing tmpl with data, or fallback if
+// there is no template or it cannot be executed.
+func spanName(tmpl *template.Template, fallback string, data cfg.SpanNameData) string {
+	if tmpl == nil {
+		return fallback
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil || b.Len() == 0 {
+		return fallback
+	}
+	return b.String()
+}
@@ -14,59 +14,205 @@ import (
 )
 
 type Run struct {
-	Collection        map[string]*Collection
+	// Collection holds the packages of the run. The same package can be
+	// part of several shards of a run.
+	Collection        map[Key]*Collection
 	CollectionDivider string
+	// Builds holds the output of packages built before running tests,
+	// keyed by import path.
+	Builds map[Key]*Build
 
 	Events        []Event
 	EarliestEvent time.Time
 	LastEvent     time.Time
 
+	// Context refers to the run's root span, which is only started
+	// once the run is stopped and its time range is known.
 	Context        context.Context
 	Fields         cfg.Tags
 	Tracer         trace.Tracer
 	TracingOptions cfg.TracingOptions
+	RunOptions     cfg.RunOptions
 	TraceID        string
+	// RootOnly makes the run only send its root span, for completing
+	// the trace of shards which sent their own spans.
+	RootOnly bool
 
-	after func()
+	shards map[string]*shard
+	after  func() error
+	err    error
 }
 
-func New(fields cfg.Tags, tracingOptions cfg.TracingOptions) (*Run, error) {
-	tp, err := tracing.JaegerProvider(tracingOptions.URL)
+// Key identifies a package, or the build of a package, in a shard of the
+// run.
+type Key struct {
+	Shard   string
+	Package string
+}
+
+// shard is a part of a run, such as one of several CI machines running
+// a subset of the packages.
+type shard struct {
+	ctx  context.Context
+	last time.Time
+}
+
+// New returns a Run sending its spans to the tracing system configured by
+// tracingOptions, with resource added to the resource of the spans.
+func New(fields cfg.Tags, tracingOptions cfg.TracingOptions, runOptions cfg.RunOptions, resource ...attribute.KeyValue) (*Run, error) {
+	ids := tracing.NewIDGenerator(runOptions.TraceID, runOptions.TraceSeed())
+	tp, err := tracing.Provider(tracingOptions, ids, resource...)
 	if err != nil {
 		return nil, err
 	}
+	return NewWithProvider(fields, tracingOptions, runOptions, ids, tp, func() error {
+		return tp.ForceFlush(context.Background())
+	}), nil
+}
+
+// NewWithProvider returns a Run sending its spans using tp, which must
+// be built with ids as its ID generator, e.g. using
+// sdktrace.WithIDGenerator. Otherwise the run's root span doesn't get the
+// IDs the other spans refer to as their parent. flush is called when the
+// run is stopped.
+func NewWithProvider(fields cfg.Tags, tracingOptions cfg.TracingOptions, runOptions cfg.RunOptions, ids *tracing.IDGenerator, tp trace.TracerProvider, flush func() error) *Run {
+	root := ids.Root()
+	if parent := runOptions.Parent; parent.IsValid() {
+		root = root.WithTraceFlags(parent.TraceFlags()).WithTraceState(parent.TraceState())
+	}
 	tracer := tp.Tracer("go-test-runner")
 
-	ctx, span := tracer.Start(context.Background(), "test/go")
-	traceID := span.SpanContext().TraceID().String()
+	traceID := ids.TraceID.String()
 	fields["traceID"] = traceID
+	if runOptions.ID != "" {
+		fields["runID"] = runOptions.ID
+	}
+	if runOptions.Attempt != "" {
+		fields["runAttempt"] = runOptions.Attempt
+	}
 	return &Run{
-		Collection:     map[string]*Collection{},
+		Collection:     map[Key]*Collection{},
+		Builds:         map[Key]*Build{},
 		Fields:         fields,
 		Tracer:         tracer,
 		TracingOptions: tracingOptions,
+		RunOptions:     runOptions,
 		TraceID:        traceID,
-		Context:        ctx,
+		Context:        trace.ContextWithSpanContext(context.Background(), root),
+
+		shards: map[string]*shard{},
+		after:  flush,
+	}
+}
+
+// shard returns the context for events from the named shard, starting
+// the shard's span when its first event is seen.
+func (r *Run) shard(name string, ts time.Time) context.Context {
+	if name == "" {
+		return r.Context
+	}
+	s, ok := r.shards[name]
+	if !ok {
+		ctx, span := r.tracer().Start(r.Context, "test/shard", trace.WithTimestamp(ts))
+		span.SetAttributes(attribute.String("shard", name))
+		s = &shard{ctx: ctx}
+		r.shards[name] = s
+	}
+	if ts.After(s.last) {
+		s.last = ts
+	}
+	return s.ctx
+}
 
-		after: func() {
-			span.End()
-			tp.ForceFlush(context.Background())
-		},
-	}, nil
+// tracer returns the tracer for all spans but the root span.
+func (r *Run) tracer() trace.Tracer {
+	if r.RootOnly {
+		return trace.NewNoopTracerProvider().Tracer("go-test-runner")
+	}
+	return r.Tracer
 }
 
+func (r *Run) observe(ts time.Time) {
+	if ts.IsZero() {
+		return
+	}
+	if r.EarliestEvent.IsZero() || ts.Before(r.EarliestEvent) {
+		r.EarliestEvent = ts
+	}
+	if ts.After(r.LastEvent) {
+		r.LastEvent = ts
+	}
+}
+
+// Stop ends the spans of the run. The root span covers the time from the
+// first to the last event, rather than the time it took to process the
+// output. Shards of a run don't send the root span, it is sent by the
+// invocation with the run ID but without a shard.
 func (r *Run) Stop() {
-	r.after()
+	for _, s := range r.shards {
+		trace.SpanFromContext(s.ctx).End(trace.WithTimestamp(s.last))
+	}
+
+	if r.RunOptions.Shard == "" {
+		ctx := context.Background()
+		if parent := r.RunOptions.Parent; parent.IsValid() {
+			ctx = trace.ContextWithRemoteSpanContext(ctx, parent)
+		}
+		ctx = tracing.ContextWithSpanID(ctx, trace.SpanContextFromContext(r.Context).SpanID())
+		name := spanName(r.TracingOptions.SpanNames.Run, "test/go", cfg.SpanNameData{})
+		_, span := r.Tracer.Start(ctx, name, trace.WithTimestamp(r.EarliestEvent))
+		for _, event := range r.Events {
+			if raw, ok := event.Payload.(RawOutput); ok && event.Shard == "" && r.TracingOptions.LogsAsEvents {
+				span.AddEvent(raw.Line, trace.WithTimestamp(event.Timestamp))
+			}
+		}
+		span.End(trace.WithTimestamp(r.LastEvent))
+	}
+	r.err = r.after()
+}
+
+// Err returns the error from flushing the run's spans, if any.
+func (r *Run) Err() error {
+	if r.err != nil {
+		return fmt.Errorf("failed to flush traces: %w", r.err)
+	}
+	return nil
 }
 
-func (r *Run) findCollectionParent(test string) *Collection {
+// Failed reports whether any package or test in the run has failed.
+func (r *Run) Failed() bool {
+	for _, c := range r.Collection {
+		if c.State == StateFailed || c.State == StateBuildFailed {
+			return true
+		}
+		for _, t := range c.Tests {
+			if t.State == StateFailed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Incomplete reports whether any package in the run has yet to pass,
+// fail or be skipped.
+func (r *Run) Incomplete() bool {
+	for _, c := range r.Collection {
+		if !c.State.Done() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Run) findCollectionParent(shard, pkg string) *Collection {
 	if r.CollectionDivider == "" {
 		return nil
 	}
-	parts := strings.Split(test, r.CollectionDivider)
+	parts := strings.Split(pkg, r.CollectionDivider)
 	for i := range parts {
 		candidate := strings.Join(parts[:(len(parts)-i)], r.CollectionDivider)
-		if c, ok := r.Collection[candidate]; ok {
+		if c, ok := r.Collection[Key{Shard: shard, Package: candidate}]; ok {
 			return c
 		}
 	}
@@ -74,23 +220,53 @@ func (r *Run) findCollectionParent(test string) *Collection {
 }
 
 func (r *Run) Handle(event Event) error {
-	pkg := event.Package
-	c, exists := r.Collection[pkg]
+	r.observe(event.Timestamp)
+	parent := r.shard(event.Shard, event.Timestamp)
+
+	switch ev := event.Payload.(type) {
+	case RawOutput:
+		if event.Shard != "" && r.TracingOptions.LogsAsEvents {
+			trace.SpanFromContext(parent).AddEvent(ev.Line, trace.WithTimestamp(event.Timestamp))
+		}
+		r.Events = append(r.Events, event)
+		return nil
+	case BuildOutput:
+		b := r.build(event.Shard, ev.ImportPath)
+		b.Output = append(b.Output, ev.Line)
+		r.Events = append(r.Events, event)
+		return nil
+	case BuildFailed:
+		r.build(event.Shard, ev.ImportPath).Failed = true
+		r.Events = append(r.Events, event)
+		return nil
+	}
+
+	key := Key{Shard: event.Shard, Package: event.Package}
+	c, exists := r.Collection[key]
 	if !exists {
-		ctx := r.Context
-		if parent := r.findCollectionParent(event.Package); parent != nil {
+		ctx := parent
+		if parent := r.findCollectionParent(event.Shard, event.Package); parent != nil {
 			ctx = parent.ctx
 		}
-		ctx, span := r.Tracer.Start(ctx, "test/package")
+		// The span ID is known up front by the exec shim.
+		sid := tracing.PackageSpanID(trace.SpanContextFromContext(ctx).TraceID(), event.Shard, event.Package)
+		name := spanName(r.TracingOptions.SpanNames.Package, "test/package", cfg.SpanNameData{Package: event.Package, Shard: event.Shard})
+		_, span := r.tracer().Start(tracing.ContextWithSpanID(ctx, sid), name, trace.WithTimestamp(event.Timestamp))
+		ctx = trace.ContextWithSpan(ctx, span)
 		span.SetAttributes(attribute.String("packageName", event.Package))
+		span.SetAttributes(suiteAttributes(event.Package)...)
+		if event.Shard != "" {
+			span.SetAttributes(attribute.String("shard", event.Shard))
+		}
 
 		c = &Collection{
 			Package:        event.Package,
+			Shard:          event.Shard,
 			SubtestDivider: "/",
 			Tests:          map[string]*Test{},
 			ctx:            ctx,
 		}
-		r.Collection[pkg] = c
+		r.Collection[key] = c
 	}
 
 	r.addToCollection(c, event)
@@ -99,8 +275,26 @@ func (r *Run) Handle(event Event) error {
 	return nil
 }
 
-func (r *Run) Get(pkg string, test string) (*Test, error) {
-	val, ok := r.Collection[pkg]
+func (r *Run) build(shard, importPath string) *Build {
+	key := Key{Shard: shard, Package: importPath}
+	b, ok := r.Builds[key]
+	if !ok {
+		b = &Build{ImportPath: importPath}
+		r.Builds[key] = b
+	}
+	return b
+}
+
+// Build is the output of building a package.
+type Build struct {
+	ImportPath string
+	Output     []string
+	Failed     bool
+}
+
+// Get returns the test of pkg in shard.
+func (r *Run) Get(shard, pkg, test string) (*Test, error) {
+	val, ok := r.Collection[Key{Shard: shard, Package: pkg}]
 	if !ok {
 		return nil, fmt.Errorf("package %s is not part of the test hierarchy", pkg)
 	}
@@ -112,12 +306,23 @@ func (r *Run) Get(pkg string, test string) (*Test, error) {
 }
 
 type Collection struct {
-	Package        string
+	Package string
+	// Shard is the part of a sharded run the package ran in.
+	Shard          string
 	Tests          map[string]*Test
 	SubtestDivider string
 
 	State  State
 	Events []Event
+	// FailedBuild is the import path of the package which failed to
+	// build, if the collection's state is StateBuildFailed.
+	FailedBuild string
+	// Usage is the resources used by the package's test binary, if they
+	// were measured.
+	Usage *ResourceUsage
+	// Profiles are the paths of the profiles saved for the package's
+	// test binary.
+	Profiles []string
 
 	ctx context.Context
 }
@@ -128,7 +333,21 @@ func (r *Run) addToCollection(c *Collection, event Event) {
 
 	if test == "" {
 		c.Events = append(c.Events, event)
-		handlePayload(c, event.Payload, r.TracingOptions.LogsAsEvents)
+		if ev, ok := event.Payload.(StateChange); ok {
+			if ev.NewState == StateBuildFailed {
+				r.linkBuild(c, ev.FailedBuild, event.Timestamp)
+			}
+			if ev.Usage != nil {
+				c.Usage = ev.Usage
+			}
+			if len(ev.Profiles) > 0 {
+				c.Profiles = ev.Profiles
+			}
+		}
+		r.handlePayload(c, event)
+		if c.State.Done() {
+			c.endTests(event.Timestamp)
+		}
 		return
 	}
 
@@ -138,18 +357,62 @@ func (r *Run) addToCollection(c *Collection, event Event) {
 		if parent := c.findTestParent(test); parent != nil {
 			ctx = parent.ctx
 		}
-		ctx, span := r.Tracer.Start(ctx, "test/runTest")
+		name := spanName(r.TracingOptions.SpanNames.Test, "test/runTest", cfg.SpanNameData{Package: pkg, Test: test, Shard: event.Shard})
+		ctx, span := r.tracer().Start(ctx, name, trace.WithTimestamp(event.Timestamp))
 		span.SetAttributes(attribute.String("name", test), attribute.String("package", pkg))
+		span.SetAttributes(caseAttributes(pkg, test)...)
+		if event.Shard != "" {
+			span.SetAttributes(attribute.String("shard", event.Shard))
+		}
 		t = &Test{
-			Package: pkg,
-			Name:    test,
-			ctx:     ctx,
+			Package:    pkg,
+			Name:       test,
+			Attributes: map[string]string{},
+			ctx:        ctx,
 		}
 		c.Tests[test] = t
 	}
 
 	t.Events = append(t.Events, event)
-	handlePayload(t, event.Payload, r.TracingOptions.LogsAsEvents)
+	switch ev := event.Payload.(type) {
+	case Attribute:
+		t.Attributes[ev.Key] = ev.Value
+	case Artifacts:
+		t.Artifacts = ev.Path()
+	case Paused:
+		t.pausedAt = event.Timestamp
+	case Continued:
+		r.recordPause(t, event.Timestamp)
+	}
+	r.handlePayload(t, event)
+}
+
+// linkBuild records which build caused the collection to fail, and adds
+// the build's output to the collection's span.
+func (r *Run) linkBuild(c *Collection, importPath string, ts time.Time) {
+	c.FailedBuild = importPath
+	span := trace.SpanFromContext(c.ctx)
+	span.SetAttributes(attribute.String("failedBuild", importPath))
+	if b, ok := r.Builds[Key{Shard: c.Shard, Package: importPath}]; ok {
+		span.AddEvent("build output", trace.WithTimestamp(ts), trace.WithAttributes(
+			attribute.String("importPath", importPath),
+			attribute.String("output", strings.Join(b.Output, "")),
+		))
+	}
+}
+
+// recordPause adds a child span to the test's span covering the time
+// the test was paused, waiting for a parallel slot.
+func (r *Run) recordPause(t *Test, continued time.Time) {
+	if t.pausedAt.IsZero() || continued.IsZero() {
+		return
+	}
+	_, span := r.tracer().Start(t.ctx, "test/paused", trace.WithTimestamp(t.pausedAt))
+	span.End(trace.WithTimestamp(continued))
+
+	t.Paused += continued.Sub(t.pausedAt)
+	t.pausedAt = time.Time{}
+	trace.SpanFromContext(t.ctx).SetAttributes(attribute.Float64("paused", t.Paused.Seconds()))
 }
 
 func (c *Collection) findTestParent(test string) *Test {
@@ -166,6 +429,16 @@ func (c *Collection) findTestParent(test string) *Test {
 	return nil
 }
 
+// endTests ends the spans of tests which never reported a final state,
+// such as benchmarks which didn't print any output.
+func (c *Collection) endTests(ts time.Time) {
+	for _, t := range c.Tests {
+		if !t.State.Done() {
+			trace.SpanFromContext(t.ctx).End(trace.WithTimestamp(ts))
+		}
+	}
+}
+
 func (c *Collection) Context() context.Context {
 	return c.ctx
 }
@@ -174,35 +447,94 @@ func (c *Collection) SetState(state State) {
 	c.State = state
 }
 
+func (c *Collection) status(state State) (attribute.KeyValue, bool) {
+	return suiteStatus(state)
+}
+
 type updateState interface {
 	Context() context.Context
 	SetState(State)
+	// status returns the semantic convention status attribute for state,
+	// if there is one.
+	status(State) (attribute.KeyValue, bool)
 }
 
-func handlePayload(handler updateState, payload EventPayload, logsToEvents bool) {
+func (r *Run) handlePayload(handler updateState, event Event) {
 	span := trace.SpanFromContext(handler.Context())
-	switch ev := payload.(type) {
+	end := trace.WithTimestamp(event.Timestamp)
+	switch ev := event.Payload.(type) {
 	case StateChange:
 		state := ev.NewState
 		handler.SetState(state)
 		span.SetAttributes(
 			attribute.String("state", state.String()),
 		)
+		if status, ok := handler.status(state); ok {
+			span.SetAttributes(status)
+		}
+		if ev.Elapsed != 0 {
+			span.SetAttributes(attribute.Float64("elapsed", ev.Elapsed.Seconds()))
+		}
+		if ev.Usage != nil {
+			span.SetAttributes(ev.Usage.Attributes()...)
+		}
+		if len(ev.Profiles) > 0 {
+			span.SetAttributes(attribute.StringSlice("profiles", ev.Profiles))
+		}
 		switch state {
 		case StatePassed:
 			span.SetStatus(codes.Ok, "test passed")
-			span.End()
+			span.End(end)
 		case StateFailed:
 			span.SetStatus(codes.Error, "test failed")
-			span.End()
+			span.End(end)
+		case StateBuildFailed:
+			span.SetStatus(codes.Error, "build failed")
+			span.End(end)
 		case StateSkipped:
-			span.SetName("test/skipPackage")
+			span.SetName(spanName(r.TracingOptions.SpanNames.Skipped, "test/skipPackage", cfg.SpanNameData{
+				Package: event.Package,
+				Test:    event.Test,
+				Shard:   event.Shard,
+			}))
 			span.SetStatus(codes.Ok, "test skipped")
-			span.End()
+			span.End(end)
+		}
+	case Started:
+		handler.SetState(StateRunning)
+		span.SetAttributes(attribute.String("state", StateRunning.String()))
+	case Paused:
+		handler.SetState(StatePaused)
+		span.SetAttributes(attribute.String("state", StatePaused.String()))
+	case Continued:
+		handler.SetState(StateRunning)
+		span.SetAttributes(attribute.String("state", StateRunning.String()))
+	case Benchmarked:
+		handler.SetState(StatePassed)
+		span.SetAttributes(attribute.String("state", StatePassed.String()))
+		if status, ok := handler.status(StatePassed); ok {
+			span.SetAttributes(status)
+		}
+		if ev.Elapsed != 0 {
+			span.SetAttributes(attribute.Float64("elapsed", ev.Elapsed.Seconds()))
+		}
+		span.SetStatus(codes.Ok, "benchmark passed")
+		span.End(end)
+	case Attribute:
+		// Namespaced to not overwrite the attributes set by
+		// go-test-runner.
+		span.SetAttributes(attribute.String("test.attr."+ev.Key, ev.Value))
+	case Artifacts:
+		span.SetAttributes(attribute.String("artifacts", ev.Path()))
+	case BenchmarkResult:
+		attrs := ev.Attributes()
+		span.AddEvent("benchmark", trace.WithTimestamp(event.Timestamp), trace.WithAttributes(attrs...))
+		if event.Test != "" {
+			span.SetAttributes(attrs...)
 		}
 	case Print:
-		if logsToEvents {
-			span.AddEvent(ev.Line)
+		if r.TracingOptions.LogsAsEvents {
+			span.AddEvent(ev.Line, trace.WithTimestamp(event.Timestamp))
 		}
 	}
 }
@@ -213,8 +545,17 @@ type Test struct {
 
 	State  State
 	Events []Event
-
-	ctx context.Context
+	// Paused is the total time the test has spent paused, waiting for
+	// a parallel slot.
+	Paused time.Duration
+	// Attributes are the key/value pairs set using testing.T.Attr.
+	Attributes map[string]string
+	// Artifacts is the directory with the test's artifacts, see
+	// testing.T.ArtifactDir.
+	Artifacts string
+
+	ctx      context.Context
+	pausedAt time.Time
 }
 
 func (t *Test) Context() context.Context {
@@ -225,6 +566,10 @@ func (t *Test) SetState(state State) {
 	t.State = state
 }
 
+func (t *Test) status(state State) (attribute.KeyValue, bool) {
+	return caseResult(state)
+}
+
 func (t *Test) TimeRange() (time.Time, time.Time) {
 	if len(t.Events) == 0 {
 		return time.Time{}, time.Time{}
@@ -240,6 +585,8 @@ const (
 	StatePassed
 	StateFailed
 	StateSkipped
+	StatePaused
+	StateBuildFailed
 )
 
 func (s State) String() string {
@@ -252,14 +599,25 @@ func (s State) String() string {
 		return "failed"
 	case StateSkipped:
 		return "skipped"
+	case StatePaused:
+		return "paused"
+	case StateBuildFailed:
+		return "build-failed"
 	default:
 		return "unknown"
 	}
 }
 
+// Done reports whether the state is final.
+func (s State) Done() bool {
+	return s == StatePassed || s == StateFailed || s == StateSkipped || s == StateBuildFailed
+}
+
 type Event struct {
 	Package string
 	Test    string
+	// Shard is the part of a sharded run the event is from.
+	Shard string
 
 	Timestamp time.Time
 	Payload   EventPayload
@@ -270,7 +628,17 @@ type EventPayload interface {
 }
 
 type StateChange struct {
-	NewState State `json:"new_state"`
+	NewState State         `json:"new_state"`
+	Elapsed  time.Duration `json:"elapsed,omitempty"`
+	// FailedBuild is the import path of the package which failed to
+	// build when NewState is StateBuildFailed.
+	FailedBuild string `json:"failed_build,omitempty"`
+	// Usage is the resources used by the package's test binary when the
+	// package is done, if it was measured.
+	Usage *ResourceUsage `json:"usage,omitempty"`
+	// Profiles are the paths of the profiles saved for the package's
+	// test binary when the package is done.
+	Profiles []string `json:"profiles,omitempty"`
 }
 
 func (StateChange) isEventPayload() {}
@@ -280,3 +648,79 @@ type Print struct {
 }
 
 func (Print) isEventPayload() {}
+
+// RawOutput is a line of input which isn't part of the `go test -json`
+// stream, such as build errors or output from -exec wrappers.
+type RawOutput struct {
+	Line string `json:"line"`
+}
+
+func (RawOutput) isEventPayload() {}
+
+// Attribute is a key/value pair set using testing.T.Attr.
+type Attribute struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func (Attribute) isEventPayload() {}
+
+// Artifacts is sent when a test creates an artifact directory using
+// testing.T.ArtifactDir while running with -artifacts.
+type Artifacts struct {
+	// Dir is the directory the test writes its artifacts to.
+	Dir string `json:"dir"`
+	// Saved is where the artifacts are copied to once the test has
+	// finished, if they are collected.
+	Saved string `json:"saved,omitempty"`
+}
+
+func (Artifacts) isEventPayload() {}
+
+// Path returns where the artifacts can be found after the run.
+func (a Artifacts) Path() string {
+	if a.Saved != "" {
+		return a.Saved
+	}
+	return a.Dir
+}
+
+// BuildOutput is a line of output from building a package.
+type BuildOutput struct {
+	ImportPath string `json:"import_path"`
+	Line       string `json:"line"`
+}
+
+func (BuildOutput) isEventPayload() {}
+
+// BuildFailed is sent when a package failed to build. Packages depending
+// on it change to StateBuildFailed.
+type BuildFailed struct {
+	ImportPath string `json:"import_path"`
+}
+
+func (BuildFailed) isEventPayload() {}
+
+// Started is sent when a test starts running.
+type Started struct{}
+
+func (Started) isEventPayload() {}
+
+// Paused is sent when a parallel test is paused, waiting for a parallel
+// slot to become available.
+type Paused struct{}
+
+func (Paused) isEventPayload() {}
+
+// Continued is sent when a paused test continues running.
+type Continued struct{}
+
+func (Continued) isEventPayload() {}
+
+// Benchmarked is sent when a benchmark which printed log output
+// finishes without failing.
+type Benchmarked struct {
+	Elapsed time.Duration `json:"elapsed,omitempty"`
+}
+
+func (Benchmarked) isEventPayload() {}
@@ -0,0 +1,294 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/grafana/go-test-runner/internal/cfg"
+	"github.com/grafana/go-test-runner/internal/tracing"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// record runs the `go test -json` output in lines through a Run and
+// returns the ended spans by name, or by name and the name attribute for
+// test spans.
+func record(t *testing.T, divider string, lines ...string) (*Run, map[string]sdktrace.ReadOnlySpan) {
+	t.Helper()
+	return recordWithOptions(t, recordOptions{}, divider, lines...)
+}
+
+// recordOptions configure the Run and tracer provider used by record.
+type recordOptions struct {
+	tracing cfg.TracingOptions
+	// sampler replaces the tracer provider's default sampler.
+	sampler sdktrace.Sampler
+	// rootOnly sets Run.RootOnly.
+	rootOnly bool
+}
+
+func recordWithOptions(t *testing.T, opts recordOptions, divider string, lines ...string) (*Run, map[string]sdktrace.ReadOnlySpan) {
+	t.Helper()
+	return recordShards(t, opts, divider, shardInput{lines: lines})
+}
+
+// shardInput is the `go test -json` output of a shard of a run.
+type shardInput struct {
+	shard string
+	lines []string
+}
+
+// recordShards is like record, for runs with several shards whose input
+// is handled one after another like in merge mode. The keys of spans
+// from a shard end with "@" and the shard's name.
+func recordShards(t *testing.T, opts recordOptions, divider string, inputs ...shardInput) (*Run, map[string]sdktrace.ReadOnlySpan) {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	ids := tracing.NewIDGenerator(trace.TraceID{}, "")
+	providerOptions := []sdktrace.TracerProviderOption{
+		sdktrace.WithSpanProcessor(recorder),
+		sdktrace.WithIDGenerator(ids),
+	}
+	if opts.sampler != nil {
+		providerOptions = append(providerOptions, sdktrace.WithSampler(opts.sampler))
+	}
+	tp := sdktrace.NewTracerProvider(providerOptions...)
+	r := NewWithProvider(cfg.Tags{}, opts.tracing, cfg.RunOptions{}, ids, tp, func() error {
+		return tp.ForceFlush(context.Background())
+	})
+	r.CollectionDivider = divider
+	r.RootOnly = opts.rootOnly
+
+	for _, input := range inputs {
+		j := NewGoJSON(strings.NewReader(strings.Join(input.lines, "\n")))
+		j.Shard = input.shard
+		for {
+			events, err := j.ReadLine()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			for _, e := range events {
+				require.NoError(t, r.Handle(e))
+			}
+		}
+	}
+	r.Stop()
+	require.NoError(t, r.Err())
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		key, shard := span.Name(), ""
+		for _, attr := range span.Attributes() {
+			switch attr.Key {
+			case "name", "packageName":
+				key += " " + attr.Value.AsString()
+			case "shard":
+				shard = "@" + attr.Value.AsString()
+			}
+		}
+		key += shard
+		require.NotContains(t, spans, key)
+		spans[key] = span
+	}
+	return r, spans
+}
+
+func requireParent(t *testing.T, spans map[string]sdktrace.ReadOnlySpan, parent, child string) {
+	t.Helper()
+
+	require.Contains(t, spans, parent)
+	require.Contains(t, spans, child)
+	require.Equal(t, spans[parent].SpanContext().SpanID(), spans[child].Parent().SpanID(), "parent of %s", child)
+	require.Equal(t, spans[parent].SpanContext().TraceID(), spans[child].SpanContext().TraceID(), "trace of %s", child)
+}
+
+func TestRunPackageSpans(t *testing.T) {
+	r, spans := record(t, "",
+		`{"Time":"2023-03-01T10:00:00Z","Action":"start","Package":"example.com/a"}`,
+		`{"Time":"2023-03-01T10:00:01Z","Action":"run","Package":"example.com/a","Test":"TestA"}`,
+		`{"Time":"2023-03-01T10:00:02Z","Action":"pass","Package":"example.com/a","Test":"TestA","Elapsed":1}`,
+		`{"Time":"2023-03-01T10:00:03Z","Action":"pass","Package":"example.com/a","Elapsed":3}`,
+		`{"Time":"2023-03-01T10:00:00Z","Action":"start","Package":"example.com/b"}`,
+		`{"Time":"2023-03-01T10:00:04Z","Action":"fail","Package":"example.com/b","Elapsed":4}`,
+	)
+
+	require.Len(t, spans, 4)
+	root := spans["test/go"]
+	require.False(t, root.Parent().IsValid())
+	require.Equal(t, r.TraceID, root.SpanContext().TraceID().String())
+	require.Equal(t, r.EarliestEvent, root.StartTime())
+	require.Equal(t, r.LastEvent, root.EndTime())
+
+	requireParent(t, spans, "test/go", "test/package example.com/a")
+	requireParent(t, spans, "test/go", "test/package example.com/b")
+	requireParent(t, spans, "test/package example.com/a", "test/runTest TestA")
+}
+
+func TestRunSubtestSpans(t *testing.T) {
+	_, spans := record(t, "",
+		`{"Time":"2023-03-01T10:00:00Z","Action":"start","Package":"example.com/a"}`,
+		`{"Time":"2023-03-01T10:00:01Z","Action":"run","Package":"example.com/a","Test":"TestA"}`,
+		`{"Time":"2023-03-01T10:00:01Z","Action":"run","Package":"example.com/a","Test":"TestA/sub"}`,
+		`{"Time":"2023-03-01T10:00:01Z","Action":"run","Package":"example.com/a","Test":"TestA/sub/deeper"}`,
+		`{"Time":"2023-03-01T10:00:01Z","Action":"run","Package":"example.com/a","Test":"TestA/other"}`,
+		`{"Time":"2023-03-01T10:00:02Z","Action":"pass","Package":"example.com/a","Test":"TestA/sub/deeper"}`,
+		`{"Time":"2023-03-01T10:00:02Z","Action":"pass","Package":"example.com/a","Test":"TestA/sub"}`,
+		`{"Time":"2023-03-01T10:00:02Z","Action":"fail","Package":"example.com/a","Test":"TestA/other"}`,
+		`{"Time":"2023-03-01T10:00:02Z","Action":"fail","Package":"example.com/a","Test":"TestA"}`,
+		`{"Time":"2023-03-01T10:00:03Z","Action":"fail","Package":"example.com/a"}`,
+	)
+
+	requireParent(t, spans, "test/package example.com/a", "test/runTest TestA")
+	requireParent(t, spans, "test/runTest TestA", "test/runTest TestA/sub")
+	requireParent(t, spans, "test/runTest TestA/sub", "test/runTest TestA/sub/deeper")
+	requireParent(t, spans, "test/runTest TestA", "test/runTest TestA/other")
+}
+
+func TestRunCollectionDivider(t *testing.T) {
+	lines := []string{
+		`{"Time":"2023-03-01T10:00:00Z","Action":"start","Package":"example.com/a"}`,
+		`{"Time":"2023-03-01T10:00:00Z","Action":"start","Package":"example.com/a/b"}`,
+		`{"Time":"2023-03-01T10:00:00Z","Action":"start","Package":"example.com/a/b/c"}`,
+		`{"Time":"2023-03-01T10:00:00Z","Action":"start","Package":"example.com/ab"}`,
+		`{"Time":"2023-03-01T10:00:01Z","Action":"pass","Package":"example.com/a/b/c"}`,
+		`{"Time":"2023-03-01T10:00:01Z","Action":"pass","Package":"example.com/a/b"}`,
+		`{"Time":"2023-03-01T10:00:01Z","Action":"pass","Package":"example.com/a"}`,
+		`{"Time":"2023-03-01T10:00:01Z","Action":"pass","Package":"example.com/ab"}`,
+	}
+
+	_, spans := record(t, "/", lines...)
+	requireParent(t, spans, "test/go", "test/package example.com/a")
+	requireParent(t, spans, "test/package example.com/a", "test/package example.com/a/b")
+	requireParent(t, spans, "test/package example.com/a/b", "test/package example.com/a/b/c")
+	requireParent(t, spans, "test/go", "test/package example.com/ab")
+
+	_, spans = record(t, "", lines...)
+	requireParent(t, spans, "test/go", "test/package example.com/a")
+	requireParent(t, spans, "test/go", "test/package example.com/a/b")
+	requireParent(t, spans, "test/go", "test/package example.com/a/b/c")
+	requireParent(t, spans, "test/go", "test/package example.com/ab")
+}
+
+func TestRunSemanticConventions(t *testing.T) {
+	_, spans := record(t, "",
+		`{"Time":"2023-03-01T10:00:00Z","Action":"start","Package":"example.com/a"}`,
+		`{"Time":"2023-03-01T10:00:01Z","Action":"run","Package":"example.com/a","Test":"TestA"}`,
+		`{"Time":"2023-03-01T10:00:01Z","Action":"run","Package":"example.com/a","Test":"TestA/sub"}`,
+		`{"Time":"2023-03-01T10:00:02Z","Action":"fail","Package":"example.com/a","Test":"TestA/sub"}`,
+		`{"Time":"2023-03-01T10:00:02Z","Action":"fail","Package":"example.com/a","Test":"TestA"}`,
+		`{"Time":"2023-03-01T10:00:03Z","Action":"fail","Package":"example.com/a"}`,
+	)
+
+	require.Subset(t, spans["test/package example.com/a"].Attributes(), []attribute.KeyValue{
+		attribute.String("test.suite.name", "example.com/a"),
+		attribute.String("test.suite.run.status", "failure"),
+	})
+	require.Subset(t, spans["test/runTest TestA/sub"].Attributes(), []attribute.KeyValue{
+		attribute.String("test.suite.name", "example.com/a"),
+		attribute.String("test.case.name", "example.com/a.TestA/sub"),
+		attribute.String("test.case.result.status", "fail"),
+		attribute.String("code.namespace", "example.com/a"),
+		attribute.String("code.function", "TestA"),
+	})
+}
+
+func TestRunSpanNames(t *testing.T) {
+	opts, err := cfg.Config{
+		cfg.TracingSpanNamePackage: "package {{.Package}}",
+		cfg.TracingSpanNameTest:    "{{.Test}}",
+		cfg.TracingSpanNameSkipped: "skipped {{.Package}} {{.Test}}",
+	}.Tracing()
+	require.NoError(t, err)
+
+	_, spans := recordWithOptions(t, recordOptions{tracing: opts}, "",
+		`{"Time":"2023-03-01T10:00:00Z","Action":"start","Package":"example.com/a"}`,
+		`{"Time":"2023-03-01T10:00:01Z","Action":"run","Package":"example.com/a","Test":"TestA"}`,
+		`{"Time":"2023-03-01T10:00:02Z","Action":"pass","Package":"example.com/a","Test":"TestA"}`,
+		`{"Time":"2023-03-01T10:00:01Z","Action":"run","Package":"example.com/a","Test":"TestB"}`,
+		`{"Time":"2023-03-01T10:00:02Z","Action":"skip","Package":"example.com/a","Test":"TestB"}`,
+		`{"Time":"2023-03-01T10:00:03Z","Action":"pass","Package":"example.com/a"}`,
+	)
+
+	require.Contains(t, spans, "test/go")
+	require.Contains(t, spans, "package example.com/a example.com/a")
+	require.Contains(t, spans, "TestA TestA")
+	require.Contains(t, spans, "skipped example.com/a TestB TestB")
+}
+
+func TestRunTestAttributes(t *testing.T) {
+	_, spans := record(t, "",
+		`{"Time":"2023-03-01T10:00:00Z","Action":"start","Package":"example.com/a"}`,
+		`{"Time":"2023-03-01T10:00:01Z","Action":"run","Package":"example.com/a","Test":"TestA"}`,
+		`{"Time":"2023-03-01T10:00:01Z","Action":"attr","Package":"example.com/a","Test":"TestA","Key":"state","Value":"custom"}`,
+		`{"Time":"2023-03-01T10:00:02Z","Action":"pass","Package":"example.com/a","Test":"TestA"}`,
+		`{"Time":"2023-03-01T10:00:03Z","Action":"pass","Package":"example.com/a"}`,
+	)
+
+	require.Subset(t, spans["test/runTest TestA"].Attributes(), []attribute.KeyValue{
+		attribute.String("state", "passed"),
+		attribute.String("test.attr.state", "custom"),
+	})
+}
+
+func TestRunShardsWithSamePackages(t *testing.T) {
+	lines := func(state string) []string {
+		return []string{
+			`{"Time":"2023-03-01T10:00:00Z","Action":"start","Package":"example.com/a"}`,
+			`{"Time":"2023-03-01T10:00:01Z","Action":"run","Package":"example.com/a","Test":"TestA"}`,
+			`{"Time":"2023-03-01T10:00:02Z","Action":"` + state + `","Package":"example.com/a","Test":"TestA"}`,
+			`{"Time":"2023-03-01T10:00:03Z","Action":"` + state + `","Package":"example.com/a"}`,
+		}
+	}
+	r, spans := recordShards(t, recordOptions{}, "",
+		shardInput{shard: "linux", lines: lines("pass")},
+		shardInput{shard: "windows", lines: lines("fail")},
+	)
+
+	for _, shard := range []string{"linux", "windows"} {
+		requireParent(t, spans, "test/go", "test/shard@"+shard)
+		requireParent(t, spans, "test/shard@"+shard, "test/package example.com/a@"+shard)
+		requireParent(t, spans, "test/package example.com/a@"+shard, "test/runTest TestA@"+shard)
+	}
+	linux, err := r.Get("linux", "example.com/a", "TestA")
+	require.NoError(t, err)
+	require.Equal(t, StatePassed, linux.State)
+	windows, err := r.Get("windows", "example.com/a", "TestA")
+	require.NoError(t, err)
+	require.Equal(t, StateFailed, windows.State)
+}
+
+func TestRunRootOnly(t *testing.T) {
+	r, spans := recordShards(t, recordOptions{rootOnly: true}, "", shardInput{shard: "linux", lines: []string{
+		`{"Time":"2023-03-01T10:00:00Z","Action":"start","Package":"example.com/a"}`,
+		`{"Time":"2023-03-01T10:00:01Z","Action":"run","Package":"example.com/a","Test":"TestA"}`,
+		`{"Time":"2023-03-01T10:00:02Z","Action":"fail","Package":"example.com/a","Test":"TestA"}`,
+		`{"Time":"2023-03-01T10:00:03Z","Action":"fail","Package":"example.com/a"}`,
+	}})
+
+	require.True(t, r.Failed())
+	require.Len(t, spans, 1)
+	require.Contains(t, spans, "test/go")
+	require.Equal(t, r.EarliestEvent, spans["test/go"].StartTime())
+	require.Equal(t, r.LastEvent, spans["test/go"].EndTime())
+}
+
+func TestRunAlwaysSample(t *testing.T) {
+	// Only the run's spans are recorded by providers sampling every span.
+	_, spans := recordWithOptions(t, recordOptions{sampler: sdktrace.AlwaysSample()}, "",
+		`{"Time":"2023-03-01T10:00:00Z","Action":"start","Package":"example.com/a"}`,
+		`{"Time":"2023-03-01T10:00:01Z","Action":"run","Package":"example.com/a","Test":"TestA"}`,
+		`{"Time":"2023-03-01T10:00:02Z","Action":"pass","Package":"example.com/a","Test":"TestA"}`,
+		`{"Time":"2023-03-01T10:00:03Z","Action":"pass","Package":"example.com/a"}`,
+	)
+
+	require.Len(t, spans, 3)
+	requireParent(t, spans, "test/go", "test/package example.com/a")
+	requireParent(t, spans, "test/package example.com/a", "test/runTest TestA")
+}
@@ -0,0 +1,31 @@
+package tests
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ResourceUsage describes the resources used by a package's test binary.
+type ResourceUsage struct {
+	UserCPU   time.Duration `json:"user_cpu"`
+	SystemCPU time.Duration `json:"system_cpu"`
+	Wall      time.Duration `json:"wall"`
+	// MaxRSS is the maximum resident set size in bytes, it is zero when
+	// unknown.
+	MaxRSS                     int64 `json:"max_rss,omitempty"`
+	VoluntaryContextSwitches   int64 `json:"voluntary_context_switches,omitempty"`
+	InvoluntaryContextSwitches int64 `json:"involuntary_context_switches,omitempty"`
+}
+
+// Attributes returns the resource usage as span attributes.
+func (u ResourceUsage) Attributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Float64("usage.userCPU", u.UserCPU.Seconds()),
+		attribute.Float64("usage.systemCPU", u.SystemCPU.Seconds()),
+		attribute.Float64("usage.wall", u.Wall.Seconds()),
+		attribute.Int64("usage.maxRSS", u.MaxRSS),
+		attribute.Int64("usage.voluntaryContextSwitches", u.VoluntaryContextSwitches),
+		attribute.Int64("usage.involuntaryContextSwitches", u.InvoluntaryContextSwitches),
+	}
+}
@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/grafana/go-test-runner/internal/cfg"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/sdk/trace"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// fileExporter writes spans to the file at opts.URL as OTLP-JSON, one
+// line of trace data per export. The file is appended to, so that the
+// spans of the exec shim end up in the same file.
+func fileExporter(opts cfg.TracingOptions) (trace.SpanExporter, error) {
+	return otlptrace.New(context.Background(), &fileClient{path: FilePath(opts)})
+}
+
+// FilePath returns the path of the file spans are written to by the file
+// tracing kind, which is either a path or a file:// URL.
+func FilePath(opts cfg.TracingOptions) string {
+	if u, err := url.Parse(opts.URL); err == nil && u.Scheme == "file" {
+		return u.Path
+	}
+	return opts.URL
+}
+
+// fileClient is an otlptrace.Client writing to a file.
+type fileClient struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+func (c *fileClient) Start(_ context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, err := os.OpenFile(c.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	c.f = f
+	return nil
+}
+
+func (c *fileClient) Stop(_ context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.f.Close()
+}
+
+func (c *fileClient) UploadTraces(_ context.Context, spans []*tracepb.ResourceSpans) error {
+	b, err := protojson.MarshalOptions{UseEnumNumbers: true}.Marshal(&tracepb.TracesData{ResourceSpans: spans})
+	if err != nil {
+		return err
+	}
+	// OTLP-JSON has hex encoded trace and span IDs, while protojson
+	// encodes bytes using base64.
+	var data any
+	if err := json.Unmarshal(b, &data); err != nil {
+		return err
+	}
+	hexIDs(data)
+	b, err = json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.f.Write(append(b, '\n'))
+	return err
+}
+
+func hexIDs(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			switch key {
+			case "traceId", "spanId", "parentSpanId":
+				if s, ok := value.(string); ok {
+					if id, err := base64.StdEncoding.DecodeString(s); err == nil {
+						v[key] = hex.EncodeToString(id)
+					}
+				}
+			default:
+				hexIDs(value)
+			}
+		}
+	case []any:
+		for _, value := range v {
+			hexIDs(value)
+		}
+	}
+}
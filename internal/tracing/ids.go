@@ -0,0 +1,95 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// IDGenerator assigns a trace and span ID chosen up front to root spans,
+// which lets the trace ID of a run be known and referred to as a parent
+// before the run's root span is started. Other span IDs are random.
+type IDGenerator struct {
+	TraceID trace.TraceID
+	SpanID  trace.SpanID
+
+	mu sync.Mutex
+}
+
+// NewIDGenerator returns an IDGenerator using traceID if it is valid, or
+// a trace ID derived from seed. The root span ID is derived from seed or
+// the given trace ID. IDs which can't be derived are random. Every
+// invocation of go-test-runner with the same seed or trace ID shares a
+// trace and root span.
+func NewIDGenerator(traceID trace.TraceID, seed string) *IDGenerator {
+	g := &IDGenerator{TraceID: traceID}
+	if seed == "" && traceID.IsValid() {
+		seed = traceID.String()
+	}
+	if seed != "" {
+		sum := sha256.Sum256([]byte("go-test-runner/" + seed))
+		if !g.TraceID.IsValid() {
+			copy(g.TraceID[:], sum[:16])
+		}
+		copy(g.SpanID[:], sum[16:24])
+	}
+	for !g.TraceID.IsValid() {
+		g.read(g.TraceID[:])
+	}
+	for !g.SpanID.IsValid() {
+		g.read(g.SpanID[:])
+	}
+	return g
+}
+
+// Root returns the span context of the root span.
+func (g *IDGenerator) Root() trace.SpanContext {
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    g.TraceID,
+		SpanID:     g.SpanID,
+		TraceFlags: trace.FlagsSampled,
+	})
+}
+
+func (g *IDGenerator) NewIDs(_ context.Context) (trace.TraceID, trace.SpanID) {
+	return g.TraceID, g.SpanID
+}
+
+type spanIDKey struct{}
+
+// ContextWithSpanID makes the span started using ctx get the span ID id
+// rather than a random one. The returned context must not be used as the
+// parent of other spans, since they'd get the same ID.
+func ContextWithSpanID(ctx context.Context, id trace.SpanID) context.Context {
+	return context.WithValue(ctx, spanIDKey{}, id)
+}
+
+// PackageSpanID returns the span ID of the span for the package
+// importPath in shard, which lets processes running the package's tests
+// refer to the span as their parent.
+func PackageSpanID(traceID trace.TraceID, shard, importPath string) trace.SpanID {
+	sum := sha256.Sum256([]byte(traceID.String() + "/package/" + shard + "/" + importPath))
+	sid := trace.SpanID{}
+	copy(sid[:], sum[:8])
+	return sid
+}
+
+func (g *IDGenerator) NewSpanID(ctx context.Context, _ trace.TraceID) trace.SpanID {
+	if sid, ok := ctx.Value(spanIDKey{}).(trace.SpanID); ok {
+		return sid
+	}
+	sid := trace.SpanID{}
+	for !sid.IsValid() {
+		g.read(sid[:])
+	}
+	return sid
+}
+
+func (g *IDGenerator) read(b []byte) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, _ = rand.Read(b)
+}
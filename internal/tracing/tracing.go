@@ -1,26 +1,232 @@
 package tracing
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/grafana/go-test-runner/internal/cfg"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/zipkin"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"google.golang.org/grpc/credentials"
+	// Registers the gzip compressor for the OTLP gRPC exporter.
+	_ "google.golang.org/grpc/encoding/gzip"
 )
 
-func JaegerProvider(url string) (*trace.TracerProvider, error) {
-	// Create the Jaeger exporter
-	exp, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(url)))
+// exporters creates the span exporter for each kind of tracing system.
+var exporters = map[cfg.TraceExporter]func(cfg.TracingOptions) (trace.SpanExporter, error){
+	cfg.TraceExporterJaeger:   jaegerExporter,
+	cfg.TraceExporterOTLPGRPC: otlpGRPCExporter,
+	cfg.TraceExporterOTLPHTTP: otlpHTTPExporter,
+	cfg.TraceExporterZipkin:   zipkinExporter,
+	cfg.TraceExporterFile:     fileExporter,
+}
+
+// Provider returns a TracerProvider sending spans to the tracing system
+// using the exporter selected by opts.Kind. The attrs are added to the
+// resource of the spans.
+func Provider(opts cfg.TracingOptions, ids trace.IDGenerator, attrs ...attribute.KeyValue) (*trace.TracerProvider, error) {
+	if opts.Kind == cfg.TraceExporterNone {
+		// The spans still get IDs, so that the trace ID can be used to
+		// correlate logs.
+		return trace.NewTracerProvider(
+			trace.WithIDGenerator(ids),
+			trace.WithSampler(trace.NeverSample()),
+		), nil
+	}
+	newExporter, ok := exporters[opts.Kind]
+	if !ok {
+		return nil, fmt.Errorf("unsupported tracing kind %s", opts.Kind)
+	}
+	exp, err := newExporter(opts)
 	if err != nil {
 		return nil, err
 	}
+
 	tp := trace.NewTracerProvider(
 		// Always be sure to batch in production.
 		trace.WithBatcher(exp),
+		trace.WithIDGenerator(ids),
 		// Record information about this application in a Resource.
 		trace.WithResource(resource.NewWithAttributes(
 			semconv.SchemaURL,
-			semconv.ServiceNameKey.String("go-test-runner"),
+			append([]attribute.KeyValue{semconv.ServiceNameKey.String("go-test-runner")}, attrs...)...,
 		)),
 	)
 	return tp, nil
 }
+
+func jaegerExporter(opts cfg.TracingOptions) (trace.SpanExporter, error) {
+	client, err := httpClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	return jaeger.New(jaeger.WithCollectorEndpoint(
+		jaeger.WithEndpoint(opts.URL),
+		jaeger.WithHTTPClient(client),
+	))
+}
+
+func zipkinExporter(opts cfg.TracingOptions) (trace.SpanExporter, error) {
+	client, err := httpClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	return zipkin.New(opts.URL, zipkin.WithClient(client))
+}
+
+// httpClient returns a client for exporters sending spans over HTTP
+// which don't support the tracing options themselves.
+func httpClient(opts cfg.TracingOptions) (*http.Client, error) {
+	tlsConfig, err := tlsConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: headerTransport{headers: opts.Headers, next: transport},
+	}, nil
+}
+
+func otlpGRPCExporter(opts cfg.TracingOptions) (trace.SpanExporter, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, err
+	}
+	options := []otlptracegrpc.Option{
+		otlptracegrpc.WithEndpoint(u.Host),
+		otlptracegrpc.WithHeaders(opts.Headers),
+		otlptracegrpc.WithTimeout(opts.Timeout),
+	}
+	if u.Scheme == "http" {
+		options = append(options, otlptracegrpc.WithInsecure())
+	} else {
+		tlsConfig, err := tlsConfig(opts)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+	}
+	if opts.Compression == "gzip" {
+		options = append(options, otlptracegrpc.WithCompressor("gzip"))
+	}
+	return otlptracegrpc.New(context.Background(), options...)
+}
+
+func otlpHTTPExporter(opts cfg.TracingOptions) (trace.SpanExporter, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, err
+	}
+	options := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+		otlptracehttp.WithHeaders(opts.Headers),
+		otlptracehttp.WithTimeout(opts.Timeout),
+	}
+	if u.Path != "" && u.Path != "/" {
+		options = append(options, otlptracehttp.WithURLPath(u.Path))
+	}
+	if u.Scheme == "http" {
+		options = append(options, otlptracehttp.WithInsecure())
+	} else {
+		tlsConfig, err := tlsConfig(opts)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, otlptracehttp.WithTLSClientConfig(tlsConfig))
+	}
+	if opts.Compression == "gzip" {
+		options = append(options, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+	}
+	return otlptracehttp.New(context.Background(), options...)
+}
+
+func tlsConfig(opts cfg.TracingOptions) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: opts.Insecure}
+	if opts.CAFile == "" {
+		return config, nil
+	}
+	pem, err := os.ReadFile(opts.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	config.RootCAs = x509.NewCertPool()
+	if !config.RootCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA file %s", opts.CAFile)
+	}
+	return config, nil
+}
+
+// headerTransport adds headers to every request.
+type headerTransport struct {
+	headers map[string]string
+	next    http.RoundTripper
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(t.headers) > 0 {
+		req = req.Clone(req.Context())
+		for key, value := range t.headers {
+			req.Header.Set(key, value)
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+// Env returns the environment variables configuring an OpenTelemetry
+// SDK to send spans to the same tracing system as opts.
+func Env(opts cfg.TracingOptions) map[string]string {
+	switch opts.Kind {
+	case cfg.TraceExporterJaeger:
+		return map[string]string{
+			"OTEL_TRACES_EXPORTER":          "jaeger",
+			"OTEL_EXPORTER_JAEGER_ENDPOINT": opts.URL,
+		}
+	case cfg.TraceExporterZipkin:
+		return map[string]string{
+			"OTEL_TRACES_EXPORTER":          "zipkin",
+			"OTEL_EXPORTER_ZIPKIN_ENDPOINT": opts.URL,
+		}
+	case cfg.TraceExporterFile:
+		// The SDKs have no standard way of writing spans to a file.
+		return map[string]string{}
+	case cfg.TraceExporterNone:
+		return map[string]string{"OTEL_TRACES_EXPORTER": "none"}
+	}
+
+	protocol, endpoint := "grpc", opts.URL
+	if opts.Kind == cfg.TraceExporterOTLPHTTP {
+		protocol = "http/protobuf"
+		// The endpoint is used as is by the SDKs, while the exporter
+		// used by go-test-runner defaults to the standard path.
+		if u, err := url.Parse(opts.URL); err == nil && (u.Path == "" || u.Path == "/") {
+			endpoint = u.JoinPath("v1", "traces").String()
+		}
+	}
+	env := map[string]string{
+		"OTEL_TRACES_EXPORTER":                  "otlp",
+		"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT":    endpoint,
+		"OTEL_EXPORTER_OTLP_TRACES_PROTOCOL":    protocol,
+		"OTEL_EXPORTER_OTLP_TRACES_TIMEOUT":     fmt.Sprint(opts.Timeout.Milliseconds()),
+		"OTEL_EXPORTER_OTLP_TRACES_COMPRESSION": opts.Compression,
+	}
+	if len(opts.Headers) > 0 {
+		env["OTEL_EXPORTER_OTLP_TRACES_HEADERS"] = opts.HeadersString()
+	}
+	if opts.CAFile != "" {
+		env["OTEL_EXPORTER_OTLP_TRACES_CERTIFICATE"] = opts.CAFile
+	}
+	return env
+}
@@ -0,0 +1,121 @@
+package wrap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Command is a `go test` (or similar) child process whose standard
+// output is consumed by go-test-runner.
+type Command struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+}
+
+// Start spawns args as a child process. If the command is `go test`
+// and `-json` is missing, it is added to the arguments. If execShim isn't
+// empty and `-exec` is missing, it is used to run the test binaries.
+func Start(args []string, execShim []string) (*Command, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no command to run")
+	}
+	args = withJSON(args)
+	if len(execShim) > 0 {
+		args = withExec(args, execShim)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", args[0], err)
+	}
+	return &Command{cmd: cmd, stdout: stdout}, nil
+}
+
+// Stdout returns the standard output of the child process. It must be
+// read until EOF before calling Wait.
+func (c *Command) Stdout() io.Reader {
+	return c.stdout
+}
+
+// Wait waits for the child process to exit and returns its exit code.
+func (c *Command) Wait() (int, error) {
+	err := c.cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	return 0, nil
+}
+
+// Kill stops the child process without waiting for it to finish.
+func (c *Command) Kill() {
+	c.cmd.Process.Kill()
+}
+
+func withJSON(args []string) []string {
+	if !isGoTest(args) || hasFlag(args, "json") {
+		return args
+	}
+	return withFlags(args, "-json")
+}
+
+func withExec(args []string, execShim []string) []string {
+	if !isGoTest(args) || hasFlag(args, "exec") {
+		return args
+	}
+	quoted := make([]string, 0, len(execShim))
+	for _, arg := range execShim {
+		// The go command splits -exec on spaces, unless quoted.
+		if strings.ContainsAny(arg, " \t\n'") {
+			arg = `"` + arg + `"`
+		}
+		quoted = append(quoted, arg)
+	}
+	return withFlags(args, "-exec", strings.Join(quoted, " "))
+}
+
+func isGoTest(args []string) bool {
+	if len(args) < 2 || args[1] != "test" {
+		return false
+	}
+	name := filepath.Base(args[0])
+	return name == "go" || name == "go.exe"
+}
+
+// hasFlag reports whether the go command's flag name is set in args.
+func hasFlag(args []string, name string) bool {
+	for _, arg := range args[2:] {
+		if arg == "-args" {
+			break
+		}
+		for _, prefix := range []string{"-", "--"} {
+			if arg == prefix+name || strings.HasPrefix(arg, prefix+name+"=") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withFlags adds flags to the go command in args.
+func withFlags(args []string, flags ...string) []string {
+	withFlags := make([]string, 0, len(args)+len(flags))
+	withFlags = append(withFlags, args[:2]...)
+	withFlags = append(withFlags, flags...)
+	return append(withFlags, args[2:]...)
+}
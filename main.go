@@ -3,14 +3,24 @@ package main
 import (
 	"errors"
 	"flag"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/go-kit/log"
+	"github.com/grafana/go-test-runner/internal/artifacts"
+	"github.com/grafana/go-test-runner/internal/bench"
 	"github.com/grafana/go-test-runner/internal/cfg"
 	"github.com/grafana/go-test-runner/internal/console"
+	"github.com/grafana/go-test-runner/internal/detect"
 	"github.com/grafana/go-test-runner/internal/loki"
+	"github.com/grafana/go-test-runner/internal/shim"
 	"github.com/grafana/go-test-runner/internal/tests"
+	"github.com/grafana/go-test-runner/internal/tracing"
+	"github.com/grafana/go-test-runner/internal/wrap"
 )
 
 type eventHandler interface {
@@ -21,12 +31,74 @@ type stoppable interface {
 	Stop()
 }
 
+// eventRewriter can change events before they are passed to the
+// handlers.
+type eventRewriter interface {
+	Rewrite(tests.Event) tests.Event
+}
+
+// telemetrySender is implemented by handlers which deliver data to an
+// external system and can report whether that delivery failed.
+type telemetrySender interface {
+	Err() error
+}
+
+// Exit codes used by go-test-runner. In wrap mode, a non-zero exit code
+// from the test command takes precedence, unless the command was killed
+// because processing was aborted.
+const (
+	// exitOK is returned when all packages finished without failures.
+	exitOK = 0
+	// exitTestsFailed is returned when a package or test failed.
+	exitTestsFailed = 1
+	// exitIncomplete is returned when the input ended while packages
+	// were still running.
+	exitIncomplete = 2
+	// exitInputError is returned when processing stopped because the
+	// input couldn't be read or had too many subsequent parsing errors.
+	exitInputError = 3
+	// exitTelemetryFailed is returned when EXIT_ON_TELEMETRY_ERROR is
+	// enabled and traces or logs could not be delivered.
+	exitTelemetryFailed = 4
+	// exitBenchRegression is returned by bench-compare when a benchmark
+	// regressed more than BENCH_REGRESSION_THRESHOLD.
+	exitBenchRegression = 5
+)
+
+// Modes are selected using the first argument to go-test-runner.
+const (
+	modeBenchCompare = "bench-compare"
+	modeReplay       = "replay"
+	modeMerge        = "merge"
+	// modeExec is used by wrap mode to run test binaries through
+	// go-test-runner using `go test -exec`.
+	modeExec = "exec"
+)
+
 func main() {
+	mode, args := "", os.Args[1:]
+	if len(args) > 0 && (args[0] == modeBenchCompare || args[0] == modeReplay || args[0] == modeMerge || args[0] == modeExec) {
+		mode, args = args[0], args[1:]
+	}
+
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	conf := cfg.Config{}
 	fields := cfg.Tags{}
-	flag.Var(&fields, "t", "Add a key=value pair to the log output for each test")
-	file := flag.String("c", "", "Path to configuration file")
-	flag.Parse()
+	flags.Var(&fields, "t", "Add a key=value pair to the log output for each test")
+	file := flags.String("c", "", "Path to configuration file")
+	runID := flags.String("run-id", "", "Identifier shared by all invocations of go-test-runner which are part of the run, overrides "+cfg.RunID)
+	traceID := flags.String("trace-id", "", "Hex encoded trace ID to use for the run, overrides "+cfg.TraceID)
+	traceParent := flags.String("traceparent", "", "W3C traceparent of the span the run is a part of, overrides "+cfg.TraceParent)
+	traceState := flags.String("tracestate", "", "W3C tracestate of the span the run is a part of, overrides "+cfg.TraceState)
+	baselineFile := ""
+	if mode == modeBenchCompare {
+		flags.StringVar(&baselineFile, "baseline", "", "Path to a saved `go test -json` file to compare benchmarks against")
+	}
+	rootOnly := false
+	if mode == modeMerge {
+		flags.BoolVar(&rootOnly, "root-only", false, "Only send the root span, for shards which sent their own spans and logs")
+	}
+	flags.Parse(args)
 
 	logger := log.NewLogfmtLogger(os.Stderr)
 
@@ -43,66 +115,361 @@ func main() {
 		}
 	}
 
+	// The flags take precedence over the environment, which takes
+	// precedence over the configuration file. They are also passed on
+	// to the test command this way.
+	for key, value := range map[string]string{
+		cfg.RunID:       *runID,
+		cfg.TraceID:     *traceID,
+		cfg.TraceParent: *traceParent,
+		cfg.TraceState:  *traceState,
+	} {
+		if value != "" {
+			os.Setenv("GT_"+key, value)
+		}
+	}
+
 	tracingOptions, traceErr := conf.Tracing()
 	lokiOptions, lokiErr := conf.Loki()
 	consoleOptions, consoleErr := conf.Console()
 	grafanaOptions, grafanaErr := conf.Grafana()
-	if err := errors.Join(traceErr, lokiErr, consoleErr, grafanaErr); err != nil {
+	exitOptions, exitErr := conf.Exit()
+	benchOptions, benchErr := conf.Bench()
+	outputOptions, outputErr := conf.Output()
+	runOptions, runErr := conf.Run()
+	wrapOptions, wrapErr := conf.Wrap()
+	profileOptions, profileErr := conf.Profile()
+	resourceOptions, resourceErr := conf.Resource()
+	if err := errors.Join(traceErr, lokiErr, consoleErr, grafanaErr, exitErr, benchErr, outputErr, runErr, wrapErr, profileErr, resourceErr); err != nil {
 		logger.Log("msg", "Failed to parse configuration for services", "error", err)
 		os.Exit(-1)
 	}
+	if mode == modeExec {
+		code, err := shim.Run(flags.Args(), shim.Options{
+			Tracing:   tracingOptions,
+			Run:       runOptions,
+			Profile:   profileOptions,
+			OutputDir: outputOptions.Dir,
+		})
+		if err != nil {
+			logger.Log("msg", "Failed to trace test binary", "error", err)
+		}
+		os.Exit(code)
+	}
+	if mode == modeMerge {
+		// The shards are read from the files, and the merged run sends
+		// the root span.
+		runOptions.Shard = ""
+	}
+
+	// The -t flags take precedence over the detected fields. Replayed
+	// and merged output comes from other machines or earlier runs, which
+	// this machine's environment doesn't describe.
+	var detected []detect.Attribute
+	if mode != modeReplay && mode != modeMerge {
+		var err error
+		detected, err = detect.Detect(resourceOptions)
+		if err != nil {
+			logger.Log("msg", "Failed to detect resource attributes", "error", err)
+		}
+		detect.Fields(fields, detected)
+	}
 
-	r, err := tests.New(fields, tracingOptions)
+	r, err := tests.New(fields, tracingOptions, runOptions, detect.KeyValues(detected)...)
 	if err != nil {
 		logger.Log("msg", "Failed to initialize test parser", "error", err)
 		os.Exit(-1)
 	}
 	r.CollectionDivider = "/"
+	r.RootOnly = rootOnly
 
-	logClient, err := loki.New(r, lokiOptions)
-	if err != nil {
-		logger.Log("msg", "Failed to initialize Loki sender", "error", err)
-		os.Exit(-1)
+	handlers := []eventHandler{r}
+	// The shards already sent their logs.
+	if !rootOnly {
+		logClient, err := loki.New(r, lokiOptions)
+		if err != nil {
+			logger.Log("msg", "Failed to initialize Loki sender", "error", err)
+			os.Exit(-1)
+		}
+		handlers = append(handlers, logClient)
 	}
+	consoleHandler := console.New(r.TraceID, consoleOptions, grafanaOptions, tracingOptions)
+	handlers = append(handlers, consoleHandler)
 
-	handlers := []eventHandler{
-		r,
-		logClient,
-		console.New(r.TraceID, consoleOptions, grafanaOptions),
+	var rewriters []eventRewriter
+	if outputOptions.Dir != "" {
+		collector := artifacts.New(filepath.Join(outputOptions.Dir, r.TraceID, "artifacts"), logger)
+		rewriters = append(rewriters, collector)
+		handlers = append(handlers, collector)
 	}
 
+	var baseline, benchmarks *bench.Collector
+	if mode == modeBenchCompare {
+		baseline, err = loadBaseline(baselineFile)
+		if err != nil {
+			logger.Log("msg", "Failed to load benchmark baseline", "filename", baselineFile, "error", err)
+			os.Exit(-1)
+		}
+		benchmarks = bench.NewCollector()
+		handlers = append(handlers, benchmarks)
+	}
+
+	p := pipeline{
+		logger:    logger,
+		rewriters: rewriters,
+		handlers:  handlers,
+		last:      map[string]time.Time{},
+	}
+
+	aborted := false
+	var child *wrap.Command
+	var usageDir string
+	switch args := flags.Args(); {
+	case mode == modeReplay:
+		if len(args) == 0 {
+			logger.Log("msg", "No files to replay")
+			os.Exit(-1)
+		}
+		for _, filename := range args {
+			if aborted = !p.processFile(filename, runOptions.Shard); aborted {
+				break
+			}
+		}
+	case mode == modeMerge:
+		if len(args) == 0 {
+			logger.Log("msg", "No files to merge")
+			os.Exit(-1)
+		}
+		for _, arg := range args {
+			shard, filename := shardFile(arg)
+			if aborted = !p.processFile(filename, shard); aborted {
+				break
+			}
+		}
+	case len(args) > 0:
+		var execShim []string
+		if wrapOptions.ExecShim {
+			execShim, err = execShimCommand(*file)
+			if err != nil {
+				logger.Log("msg", "Failed to set up running test binaries through go-test-runner", "error", err)
+				os.Exit(-1)
+			}
+			usageDir, err = os.MkdirTemp("", "go-test-runner-usage-")
+			if err != nil {
+				logger.Log("msg", "Failed to create directory for resource usage", "error", err)
+				os.Exit(-1)
+			}
+			p.rewriters = append(p.rewriters, shim.NewUsageReader(usageDir))
+			// The shim runs in the package's directory, so it gets absolute paths.
+			if profileOptions.Enabled {
+				outputDir, err := filepath.Abs(outputOptions.Dir)
+				if err != nil {
+					logger.Log("msg", "Failed to resolve output directory", "error", err)
+					os.Exit(-1)
+				}
+				p.rewriters = append(p.rewriters, shim.NewProfileReader(shim.ProfileDir(outputDir, r.TraceID)))
+				os.Setenv("GT_"+cfg.OutputDir, outputDir)
+			}
+			if tracingOptions.Kind == cfg.TraceExporterFile {
+				spansFile, err := filepath.Abs(tracing.FilePath(tracingOptions))
+				if err != nil {
+					logger.Log("msg", "Failed to resolve tracing file", "error", err)
+					os.Exit(-1)
+				}
+				os.Setenv("GT_"+cfg.TracingURL, spansFile)
+			}
+			if tracingOptions.CAFile != "" {
+				caFile, err := filepath.Abs(tracingOptions.CAFile)
+				if err != nil {
+					logger.Log("msg", "Failed to resolve tracing CA file", "error", err)
+					os.Exit(-1)
+				}
+				os.Setenv("GT_"+cfg.TracingCAFile, caFile)
+			}
+			// The shim gets the trace ID, the detected resource attributes
+			// and where to save the resource usage from the environment.
+			os.Setenv("GT_"+cfg.TraceID, r.TraceID)
+			os.Setenv(shim.ResourceEnv, shim.ResourceString(detect.KeyValues(detected)))
+			os.Setenv(shim.UsageDirEnv, usageDir)
+		}
+		child, err = wrap.Start(args, execShim)
+		if err != nil {
+			logger.Log("msg", "Failed to start test command", "error", err)
+			os.Exit(-1)
+		}
+		if aborted = !p.process(child.Stdout(), runOptions.Shard); aborted {
+			child.Kill()
+		}
+	default:
+		aborted = !p.process(os.Stdin, runOptions.Shard)
+	}
+
+	regressed := false
+	if baseline != nil {
+		cs := bench.Compare(baseline, benchmarks, bench.Options{
+			Threshold: benchOptions.RegressionThreshold,
+			Alpha:     benchOptions.Alpha,
+		})
+		consoleHandler.BenchComparison(cs)
+		regressed = len(bench.Regressions(cs)) > 0
+	}
+
+	var telemetryErrs []error
+	for _, handler := range handlers {
+		if stopper, ok := handler.(stoppable); ok {
+			stopper.Stop()
+		}
+		if sender, ok := handler.(telemetrySender); ok {
+			telemetryErrs = append(telemetryErrs, sender.Err())
+		}
+	}
+
+	telemetryErr := errors.Join(telemetryErrs...)
+	if telemetryErr != nil {
+		logger.Log("msg", "Failed to deliver telemetry", "error", telemetryErr)
+	}
+
+	code := exitCode(r, aborted, regressed, exitOptions.TelemetryErrors && telemetryErr != nil)
+	if child != nil {
+		childCode, err := child.Wait()
+		if err != nil {
+			logger.Log("msg", "Failed to wait for test command", "error", err)
+		}
+		// The child's exit code is meaningless when it was killed
+		// because processing was aborted.
+		if childCode != exitOK && !aborted {
+			code = childCode
+		}
+	}
+	if usageDir != "" {
+		os.RemoveAll(usageDir)
+	}
+	os.Exit(code)
+}
+
+// pipeline passes the events read from `go test -json` output through
+// the rewriters and on to the handlers.
+type pipeline struct {
+	logger    log.Logger
+	rewriters []eventRewriter
+	handlers  []eventHandler
+	// last is the most recent timestamp of each shard, so that replaying
+	// several files continues from where the previous file ended.
+	last map[string]time.Time
+}
+
+// process handles all events read from r as part of shard. It returns
+// false if processing was stopped because r couldn't be read or had too
+// many subsequent parsing errors.
+func (p pipeline) process(r io.Reader, shard string) bool {
 	failCount := 0
-	goJSON := tests.NewGoJSON(os.Stdin)
+	goJSON := tests.NewGoJSON(r)
+	goJSON.Shard = shard
+	goJSON.Last = p.last[shard]
+	defer func() {
+		p.last[shard] = goJSON.Last
+	}()
 	for {
 		es, err := goJSON.ReadLine()
+		if errors.Is(err, io.EOF) {
+			return true
+		}
+		var parseErr *tests.ParseError
+		if err != nil && !errors.As(err, &parseErr) {
+			p.logger.Log("msg", "Failed to read from `go test -json`, stopping processing", "error", err)
+			return false
+		}
 		if err != nil {
 			failCount++
-			if err == io.EOF {
-				break
-			}
-			logger.Log("msg", "Error parsing line from `go test -json`!", "error", err)
+			p.logger.Log("msg", "Error parsing line from `go test -json`!", "error", err)
 
 			if failCount > 9 {
-				logger.Log("msg", "Too many subsequent parsing errors, stopping processing", "error", err)
-				os.Exit(-1)
+				p.logger.Log("msg", "Too many subsequent parsing errors, stopping processing", "error", err)
+				return false
 			}
 			continue
 		} else {
 			failCount = 0
 		}
 		for _, e := range es {
-			for _, handler := range handlers {
+			for _, rewriter := range p.rewriters {
+				e = rewriter.Rewrite(e)
+			}
+			for _, handler := range p.handlers {
 				err := handler.Handle(e)
 				if err != nil {
-					logger.Log("msg", "Error from handler '%T': %v", handler, err)
+					p.logger.Log("msg", "Error from handler '%T': %v", handler, err)
 				}
 			}
 		}
 	}
+}
+
+func (p pipeline) processFile(filename string, shard string) bool {
+	f, err := os.Open(filename)
+	if err != nil {
+		p.logger.Log("msg", "Failed to open file", "filename", filename, "error", err)
+		return false
+	}
+	defer f.Close()
+	return p.process(f, shard)
+}
 
-	for _, handler := range handlers {
-		if stopper, ok := handler.(stoppable); ok {
-			stopper.Stop()
+// execShimCommand returns the command which `go test -exec` runs the
+// test binaries with, using the same configuration file as this process.
+func execShimCommand(configFile string) ([]string, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	command := []string{self, modeExec}
+	if configFile != "" {
+		// The test binaries run in their package's directory.
+		configFile, err = filepath.Abs(configFile)
+		if err != nil {
+			return nil, err
 		}
+		command = append(command, "-c", configFile)
+	}
+	return command, nil
+}
+
+// shardFile splits a merge argument on the form name=path into the name
+// of the shard and the file it's read from. The name defaults to the
+// file's name without its extension.
+func shardFile(arg string) (string, string) {
+	if name, filename, ok := strings.Cut(arg, "="); ok {
+		return name, filename
+	}
+	name := filepath.Base(arg)
+	return strings.TrimSuffix(name, filepath.Ext(name)), arg
+}
+
+func exitCode(r *tests.Run, aborted bool, regressed bool, telemetryFailed bool) int {
+	switch {
+	case aborted:
+		return exitInputError
+	case r.Failed():
+		return exitTestsFailed
+	case r.Incomplete():
+		return exitIncomplete
+	case regressed:
+		return exitBenchRegression
+	case telemetryFailed:
+		return exitTelemetryFailed
+	default:
+		return exitOK
+	}
+}
+
+func loadBaseline(filename string) (*bench.Collector, error) {
+	if filename == "" {
+		return nil, fmt.Errorf("%s requires a baseline file set using -baseline", modeBenchCompare)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
 	}
+	defer f.Close()
+	return bench.Load(f)
 }